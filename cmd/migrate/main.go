@@ -6,6 +6,7 @@ import (
 	"os"
 	"rtcs/internal/config"
 	"rtcs/internal/model"
+	"rtcs/internal/repository"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -27,12 +28,7 @@ func main() {
 
 	// Run migrations
 	log.Printf("Running migrations...")
-	if err := db.AutoMigrate(
-		&model.User{},
-		&model.Chat{},
-		&model.ChatUser{},
-		&model.Message{},
-	); err != nil {
+	if err := repository.Migrate(db); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 	log.Printf("Migrations completed")
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rtcs/internal/config"
+	"rtcs/internal/repository"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// purge permanently removes message tombstones older than the retention
+// period. It is meant to be run by an administrator or a scheduled job.
+func main() {
+	cfg := config.Get()
+
+	retention := flag.Duration("retention", cfg.TombstoneRetention, "keep tombstones deleted more recently than this")
+	flag.Parse()
+
+	if *retention < 0 {
+		log.Fatalf("Retention cannot be negative: %s", *retention)
+	}
+	log.Printf("Purging message tombstones older than %s...", *retention)
+
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	log.Printf("Connected to database")
+
+	messageRepo := repository.NewMessageRepository(db)
+	purged, err := messageRepo.PurgeDeletedMessages(context.Background(), time.Now().Add(-*retention))
+	if err != nil {
+		log.Fatalf("Failed to purge tombstones: %v", err)
+	}
+
+	fmt.Printf("Purged %d message tombstones\n", purged)
+	os.Exit(0)
+}
@@ -4,16 +4,20 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"rtcs/internal/cache"
 	"rtcs/internal/circuitbreaker"
+	"rtcs/internal/cluster"
 	"rtcs/internal/config"
 	"rtcs/internal/logging"
 	"rtcs/internal/middleware"
+	"rtcs/internal/mqtt"
 	"rtcs/internal/repository"
 	"rtcs/internal/service"
+	"rtcs/internal/storage"
 	"rtcs/internal/transport"
 	httptransport "rtcs/internal/transport/http"
 	"strings"
@@ -97,10 +101,29 @@ func main() {
 
 	// Initialize services
 	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
+	authService.SetTicketStore(messageCache)
 	chatService := service.NewChatService(chatRepo)
 	messageService := service.NewMessageService(messageRepo, messageCache)
+	eventBus := service.NewEventBus()
+	messageService.SetEventPublisher(eventBus)
+	messageService.SetUserLookup(userRepo)
+	chatService.SetCache(messageCache)
+	chatAccess := service.NewChatAccess(messageRepo, messageCache)
+	chatService.SetChatAccess(chatAccess)
+	messageService.SetChatAccess(chatAccess)
+	chatService.SetEventPublisher(eventBus)
 	statusService := service.NewStatusService(rdb)
+	chatService.SetProfileLookup(userRepo)
+	chatService.SetPresenceLookup(statusService)
 	profileService := service.NewProfileService(userRepo)
+
+	blobStore, err := newBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open blob store: %v", err)
+	}
+	attachmentService := service.NewAttachmentService(messageRepo, blobStore, chatService, cfg.MaxAttachmentSize)
+	messageService.SetBlobStore(blobStore)
+	profileService.SetBlobStore(blobStore)
 	log.Printf("Services initialized")
 
 	// Initialize handlers
@@ -108,6 +131,7 @@ func main() {
 	profileHandler := transport.NewProfileHandler(profileService)
 	chatHandler := transport.NewChatHandler(chatService)
 	messageHandler := transport.NewMessageHandler(messageService)
+	attachmentHandler := transport.NewAttachmentHandler(attachmentService)
 	oauthHandler := httptransport.NewOAuthHandler(oauthCfg, authService)
 
 	// Create router
@@ -124,6 +148,7 @@ func main() {
 	authRouter.HandleFunc("/login", authHandler.Login).Methods("POST")
 	authRouter.HandleFunc("/google/login", oauthHandler.GoogleLogin).Methods("GET")
 	authRouter.HandleFunc("/google/callback", oauthHandler.GoogleCallback).Methods("GET")
+	authRouter.Handle("/ws-ticket", middleware.Auth(authService)(http.HandlerFunc(authHandler.WebSocketTicket))).Methods("POST")
 
 	// Protected routes
 	chatRouter := router.PathPrefix("/chats").Subrouter()
@@ -131,15 +156,56 @@ func main() {
 	chatRouter.HandleFunc("", chatHandler.CreateChat).Methods("POST")
 	chatRouter.HandleFunc("", chatHandler.ListChats).Methods("GET")
 	chatRouter.HandleFunc("/{chatId}", chatHandler.GetChat).Methods("GET")
+	chatRouter.HandleFunc("/{chatId}", chatHandler.UpdateChat).Methods("PATCH")
 	chatRouter.HandleFunc("/{chatId}/join", chatHandler.JoinChat).Methods("POST")
 	chatRouter.HandleFunc("/{chatId}/leave", chatHandler.LeaveChat).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/delivered", chatHandler.MarkDelivered).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/read", chatHandler.MarkRead).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/attachments", attachmentHandler.Upload).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/pins", chatHandler.ListPins).Methods("GET")
+	chatRouter.HandleFunc("/{chatId}/pins/{messageId}", chatHandler.PinMessage).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/pins/{messageId}", chatHandler.UnpinMessage).Methods("DELETE")
+	chatRouter.HandleFunc("/{chatId}/members", chatHandler.ListMembers).Methods("GET")
+	chatRouter.HandleFunc("/{chatId}/members", chatHandler.AddMember).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/members/{userId}", chatHandler.RemoveMember).Methods("DELETE")
+	chatRouter.HandleFunc("/{chatId}/members/{userId}/role", chatHandler.SetMemberRole).Methods("PUT")
+	chatRouter.HandleFunc("/{chatId}/owner", chatHandler.TransferOwnership).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/invites", chatHandler.ListInvites).Methods("GET")
+	chatRouter.HandleFunc("/{chatId}/invites", chatHandler.CreateInvite).Methods("POST")
+	chatRouter.HandleFunc("/{chatId}/invites/{inviteId}", chatHandler.RevokeInvite).Methods("DELETE")
+
+	dmRouter := router.PathPrefix("/dm").Subrouter()
+	dmRouter.Use(middleware.Auth(authService))
+	dmRouter.HandleFunc("", chatHandler.CreateGroupDM).Methods("POST")
+	dmRouter.HandleFunc("/{userId}", chatHandler.OpenDirectChat).Methods("POST")
+
+	inviteRouter := router.PathPrefix("/invites").Subrouter()
+	inviteRouter.Use(middleware.Auth(authService))
+	inviteRouter.HandleFunc("/{token}/accept", chatHandler.AcceptInvite).Methods("POST")
+
+	attachmentRouter := router.PathPrefix("/attachments").Subrouter()
+	attachmentRouter.Use(middleware.Auth(authService))
+	attachmentRouter.HandleFunc("/{attachmentId}", attachmentHandler.Download).Methods("GET")
+	attachmentRouter.HandleFunc("/{attachmentId}/thumbnail", attachmentHandler.Thumbnail).Methods("GET")
 
 	messageRouter := router.PathPrefix("/messages").Subrouter()
 	messageRouter.Use(middleware.Auth(authService))
 	messageRouter.HandleFunc("", messageHandler.Send).Methods("POST")
+	messageRouter.HandleFunc("/{messageId}", messageHandler.EditMessage).Methods("PATCH")
+	messageRouter.HandleFunc("/{messageId}/thread", messageHandler.GetThread).Methods("GET")
+	messageRouter.HandleFunc("/{messageId}/reactions", messageHandler.AddReaction).Methods("POST")
+	messageRouter.HandleFunc("/{messageId}/reactions/{emoji}", messageHandler.RemoveReaction).Methods("DELETE")
 	messageRouter.HandleFunc("/{messageId}", messageHandler.DeleteMessage).Methods("DELETE")
 	messageRouter.HandleFunc("/chat/{chatId}", messageHandler.GetChatHistory).Methods("GET")
 
+	searchRouter := router.PathPrefix("/search").Subrouter()
+	searchRouter.Use(middleware.Auth(authService))
+	searchRouter.HandleFunc("/messages", messageHandler.SearchMessages).Methods("GET")
+
+	meRouter := router.PathPrefix("/me").Subrouter()
+	meRouter.Use(middleware.Auth(authService))
+	meRouter.HandleFunc("/mentions", messageHandler.GetMentions).Methods("GET")
+
 	statusRouter := router.PathPrefix("/status").Subrouter()
 	statusRouter.Use(middleware.Auth(authService))
 	statusRouter.HandleFunc("/online", func(w http.ResponseWriter, r *http.Request) {
@@ -153,11 +219,59 @@ func main() {
 	}).Methods("GET")
 
 	// WebSocket endpoint
-	wsHandler := transport.NewWebSocketHandler(statusService, profileService) // Pass status service
+	wsHandler := transport.NewWebSocketHandler(authService, chatService, messageService, statusService, profileService)
+	wsHandler.SetEventPublisher(eventBus)
+	eventBus.Subscribe(wsHandler.HandleEvent)
+	usesMQTT := cfg.MQTTBridge || cfg.ClusterBackplane == "mqtt"
+	mqttCredentials := mqtt.Credentials{Username: cfg.MQTTUsername, Password: cfg.MQTTPassword}
+	if usesMQTT {
+		// The broker only accepts the server account, and checks it here, so
+		// this must be up before connecting
+		if cfg.MQTTPassword == "" {
+			log.Fatalf("MQTT_PASSWORD must be set to use the MQTT broker")
+		}
+		mqttAuthServer := newMQTTAuthServer(cfg.MQTTAuthAddr, transport.NewMQTTAuthHandler(authService, chatService, mqttCredentials))
+		defer mqttAuthServer.Close()
+	}
+	switch cfg.ClusterBackplane {
+	case "redis":
+		backplane := cluster.NewRedisBackplane(rdb, cluster.DefaultRedisChannel)
+		if err := wsHandler.UseBackplane(backplane, cfg.NodeID); err != nil {
+			log.Fatalf("Failed to join cluster backplane: %v", err)
+		}
+	case "mqtt":
+		backplane, err := cluster.NewMQTTBackplane(cfg.MQTTBroker, cfg.NodeID, mqttCredentials, byte(cfg.MQTTQoS))
+		if err != nil {
+			log.Fatalf("Failed to connect to MQTT backplane: %v", err)
+		}
+		defer backplane.Close()
+		if err := wsHandler.UseBackplane(backplane, cfg.NodeID); err != nil {
+			log.Fatalf("Failed to join cluster backplane: %v", err)
+		}
+	case "", "none":
+		log.Printf("Running as a single node without a cluster backplane")
+	default:
+		log.Fatalf("Unknown cluster backplane: %s", cfg.ClusterBackplane)
+	}
+	if cfg.MQTTBridge {
+		bridge, err := transport.NewMQTTBridge(cfg.MQTTBroker, cfg.NodeID, mqttCredentials, transport.MQTTBridgeOptions{
+			QoS:            byte(cfg.MQTTQoS),
+			RetainMessages: cfg.MQTTRetainMessages,
+			RetainPresence: cfg.MQTTRetainPresence,
+		}, chatService, messageService)
+		if err != nil {
+			log.Fatalf("Failed to start MQTT bridge: %v", err)
+		}
+		defer bridge.Close()
+		eventBus.Subscribe(bridge.HandleEvent)
+		log.Printf("MQTT bridge connected to %s", cfg.MQTTBroker)
+	}
 	router.HandleFunc("/ws", wsHandler.HandleWebSocket)
 	router.HandleFunc("/api/profile", profileHandler.GetMyProfile).Methods("GET")
 	router.HandleFunc("/api/profile", profileHandler.UpdateProfile).Methods("PUT")
+	router.Handle("/api/profile/avatar", middleware.Auth(authService)(http.HandlerFunc(profileHandler.UploadAvatar))).Methods("POST")
 	router.HandleFunc("/api/users/{userId}/profile", profileHandler.GetProfile).Methods("GET")
+	router.HandleFunc("/avatars/{userId}/{file}", profileHandler.GetAvatar).Methods("GET")
 	log.Printf("WebSocket endpoint added")
 
 	// Serve static files from the public directory (must be last)
@@ -225,6 +339,28 @@ func main() {
 
 }
 
+// newMQTTAuthServer serves the broker's authentication and ACL checks on an
+// address of their own, away from the public API
+func newMQTTAuthServer(addr string, handler *transport.MQTTAuthHandler) *http.Server {
+	router := mux.NewRouter()
+	router.HandleFunc("/mqtt/auth/user", handler.User).Methods("POST")
+	router.HandleFunc("/mqtt/auth/superuser", handler.Superuser).Methods("POST")
+	router.HandleFunc("/mqtt/auth/acl", handler.ACL).Methods("POST")
+
+	srv := &http.Server{Addr: addr, Handler: router}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed to listen for MQTT auth checks on %s: %v", addr, err)
+	}
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("MQTT auth server failed: %v", err)
+		}
+	}()
+	log.Printf("MQTT auth checks served on %s", addr)
+	return srv
+}
+
 func connectDB(url string) (*gorm.DB, error) {
 	log.Printf("Connecting to database...")
 	db, err := gorm.Open(postgres.Open(url), &gorm.Config{})
@@ -236,6 +372,30 @@ func connectDB(url string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// newBlobStore opens the configured store for attachments and avatars
+func newBlobStore(cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.BlobStore {
+	case "local":
+		return storage.NewLocalStore(cfg.BlobDir)
+	case "s3":
+		store := storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := store.CreateBucket(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown blob store %q", cfg.BlobStore)
+	}
+}
+
 func initCircuitBreaker() *circuitbreaker.Registry {
 	registry := circuitbreaker.NewRegistry()
 
@@ -85,14 +85,14 @@ func (c *RedisWithCircuitBreaker) DeleteMessage(ctx context.Context, messageID s
 	})
 }
 
-func (c *RedisWithCircuitBreaker) SetChatMessages(ctx context.Context, chatID string, messages []*model.Message) error {
+func (c *RedisWithCircuitBreaker) SetChatMessages(ctx context.Context, chatID, page string, messages []*model.Message) error {
 	cb := c.cbRegistry.Get("redis-set-chat-messages")
 
 	return cb.Execute(func() error {
 		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
 		defer cancel()
 
-		err := c.redis.SetChatMessages(ctx, chatID, messages)
+		err := c.redis.SetChatMessages(ctx, chatID, page, messages)
 		if err != nil {
 			log.Printf("[ERROR] Redis SetChatMessages failed: %v", err)
 		}
@@ -100,7 +100,7 @@ func (c *RedisWithCircuitBreaker) SetChatMessages(ctx context.Context, chatID st
 	})
 }
 
-func (c *RedisWithCircuitBreaker) GetChatMessages(ctx context.Context, chatID string) ([]*model.Message, error) {
+func (c *RedisWithCircuitBreaker) GetChatMessages(ctx context.Context, chatID, page string) ([]*model.Message, error) {
 	cb := c.cbRegistry.Get("redis-get-chat-messages")
 
 	var messages []*model.Message
@@ -109,7 +109,7 @@ func (c *RedisWithCircuitBreaker) GetChatMessages(ctx context.Context, chatID st
 		defer cancel()
 
 		var err error
-		messages, err = c.redis.GetChatMessages(ctx, chatID)
+		messages, err = c.redis.GetChatMessages(ctx, chatID, page)
 		if err != nil && err != redis.Nil {
 			log.Printf("[ERROR] Redis GetChatMessages failed: %v", err)
 			return err
@@ -124,3 +124,198 @@ func (c *RedisWithCircuitBreaker) GetChatMessages(ctx context.Context, chatID st
 
 	return messages, err
 }
+
+func (c *RedisWithCircuitBreaker) DeleteChatMessages(ctx context.Context, chatID string) error {
+	cb := c.cbRegistry.Get("redis-delete-chat-messages")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.DeleteChatMessages(ctx, chatID)
+		if err != nil {
+			log.Printf("[ERROR] Redis DeleteChatMessages failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) SetChatSummary(ctx context.Context, chatID string, summary *model.ChatSummary) error {
+	cb := c.cbRegistry.Get("redis-set-chat-summary")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.SetChatSummary(ctx, chatID, summary)
+		if err != nil {
+			log.Printf("[ERROR] Redis SetChatSummary failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) GetChatSummary(ctx context.Context, chatID string) (*model.ChatSummary, error) {
+	cb := c.cbRegistry.Get("redis-get-chat-summary")
+
+	var summary *model.ChatSummary
+	err := cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		var err error
+		summary, err = c.redis.GetChatSummary(ctx, chatID)
+		if err != nil && err != redis.Nil {
+			log.Printf("[ERROR] Redis GetChatSummary failed: %v", err)
+			return err
+		}
+		return nil
+	})
+
+	if err == circuitbreaker.ErrCircuitOpen {
+		log.Printf("[CIRCUIT BREAKER] Circuit is open for Redis GetChatSummary, falling back to default behavior")
+		return nil, fmt.Errorf("service temporarily unavailable: %w", err)
+	}
+
+	return summary, err
+}
+
+func (c *RedisWithCircuitBreaker) DeleteChatSummary(ctx context.Context, chatID string) error {
+	cb := c.cbRegistry.Get("redis-delete-chat-summary")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.DeleteChatSummary(ctx, chatID)
+		if err != nil {
+			log.Printf("[ERROR] Redis DeleteChatSummary failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) SetChatRole(ctx context.Context, chatID, userID string, role model.ChatRole) error {
+	cb := c.cbRegistry.Get("redis-set-chat-role")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.SetChatRole(ctx, chatID, userID, role)
+		if err != nil {
+			log.Printf("[ERROR] Redis SetChatRole failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) GetChatRole(ctx context.Context, chatID, userID string) (*model.ChatRole, error) {
+	cb := c.cbRegistry.Get("redis-get-chat-role")
+
+	var role *model.ChatRole
+	err := cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		var err error
+		role, err = c.redis.GetChatRole(ctx, chatID, userID)
+		if err != nil {
+			log.Printf("[ERROR] Redis GetChatRole failed: %v", err)
+			return err
+		}
+		return nil
+	})
+
+	if err == circuitbreaker.ErrCircuitOpen {
+		log.Printf("[CIRCUIT BREAKER] Circuit is open for Redis GetChatRole, falling back to default behavior")
+		return nil, fmt.Errorf("service temporarily unavailable: %w", err)
+	}
+
+	return role, err
+}
+
+func (c *RedisWithCircuitBreaker) DeleteChatRole(ctx context.Context, chatID, userID string) error {
+	cb := c.cbRegistry.Get("redis-delete-chat-role")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.DeleteChatRole(ctx, chatID, userID)
+		if err != nil {
+			log.Printf("[ERROR] Redis DeleteChatRole failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) SetUnreadCount(ctx context.Context, chatID, userID string, count int64) error {
+	cb := c.cbRegistry.Get("redis-set-unread-count")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.SetUnreadCount(ctx, chatID, userID, count)
+		if err != nil {
+			log.Printf("[ERROR] Redis SetUnreadCount failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) GetUnreadCount(ctx context.Context, chatID, userID string) (*int64, error) {
+	cb := c.cbRegistry.Get("redis-get-unread-count")
+
+	var count *int64
+	err := cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		var err error
+		count, err = c.redis.GetUnreadCount(ctx, chatID, userID)
+		if err != nil {
+			log.Printf("[ERROR] Redis GetUnreadCount failed: %v", err)
+			return err
+		}
+		return nil
+	})
+
+	if err == circuitbreaker.ErrCircuitOpen {
+		log.Printf("[CIRCUIT BREAKER] Circuit is open for Redis GetUnreadCount, falling back to default behavior")
+		return nil, fmt.Errorf("service temporarily unavailable: %w", err)
+	}
+
+	return count, err
+}
+
+func (c *RedisWithCircuitBreaker) DeleteUnreadCount(ctx context.Context, chatID, userID string) error {
+	cb := c.cbRegistry.Get("redis-delete-unread-count")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.DeleteUnreadCount(ctx, chatID, userID)
+		if err != nil {
+			log.Printf("[ERROR] Redis DeleteUnreadCount failed: %v", err)
+		}
+		return err
+	})
+}
+
+func (c *RedisWithCircuitBreaker) DeleteUnreadCounts(ctx context.Context, chatID string) error {
+	cb := c.cbRegistry.Get("redis-delete-unread-counts")
+
+	return cb.Execute(func() error {
+		ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+
+		err := c.redis.DeleteUnreadCounts(ctx, chatID)
+		if err != nil {
+			log.Printf("[ERROR] Redis DeleteUnreadCounts failed: %v", err)
+		}
+		return err
+	})
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rtcs/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// membershipTTL bounds how long a membership change made outside the chat
+// service, which does not invalidate the cache, can go unnoticed
+const membershipTTL = 10 * time.Minute
+
+// chatRoleKey holds a user's role in a chat, or an empty string if they are
+// not a member
+func chatRoleKey(chatID, userID string) string {
+	return fmt.Sprintf("chat:%s:member:%s", chatID, userID)
+}
+
+// getChatRole returns the cached role of a user in a chat, or nil on a miss
+func getChatRole(ctx context.Context, client *redis.Client, chatID, userID string) (*model.ChatRole, error) {
+	role, err := client.Get(ctx, chatRoleKey(chatID, userID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	chatRole := model.ChatRole(role)
+	return &chatRole, nil
+}
+
+func (c *MessageCache) SetChatRole(ctx context.Context, chatID, userID string, role model.ChatRole) error {
+	return c.client.Set(ctx, chatRoleKey(chatID, userID), string(role), membershipTTL).Err()
+}
+
+func (c *MessageCache) GetChatRole(ctx context.Context, chatID, userID string) (*model.ChatRole, error) {
+	return getChatRole(ctx, c.client, chatID, userID)
+}
+
+func (c *MessageCache) DeleteChatRole(ctx context.Context, chatID, userID string) error {
+	return c.client.Del(ctx, chatRoleKey(chatID, userID)).Err()
+}
+
+func (c *RedisCache) SetChatRole(ctx context.Context, chatID, userID string, role model.ChatRole) error {
+	return c.client.Set(ctx, chatRoleKey(chatID, userID), string(role), membershipTTL).Err()
+}
+
+func (c *RedisCache) GetChatRole(ctx context.Context, chatID, userID string) (*model.ChatRole, error) {
+	role, err := getChatRole(ctx, c.client, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat role from cache: %w", err)
+	}
+	return role, nil
+}
+
+func (c *RedisCache) DeleteChatRole(ctx context.Context, chatID, userID string) error {
+	return c.client.Del(ctx, chatRoleKey(chatID, userID)).Err()
+}
@@ -47,18 +47,82 @@ func (c *MessageCache) DeleteMessage(ctx context.Context, messageID string) erro
 	return c.client.Del(ctx, key).Err()
 }
 
-func (c *MessageCache) SetChatMessages(ctx context.Context, chatID string, messages []*model.Message) error {
-	key := fmt.Sprintf("chat:%s:messages", chatID)
+// chatMessagesKey holds one cached page of a chat's history
+func chatMessagesKey(chatID, page string) string {
+	return fmt.Sprintf("chat:%s:messages:%s", chatID, page)
+}
+
+// chatPagesKey lists the cached history pages of a chat so they can be
+// dropped together
+func chatPagesKey(chatID string) string {
+	return fmt.Sprintf("chat:%s:message_pages", chatID)
+}
+
+// setChatPage caches a page of history and records it under its chat
+func setChatPage(ctx context.Context, client *redis.Client, chatID, page string, data []byte) error {
+	pipe := client.TxPipeline()
+	pipe.Set(ctx, chatMessagesKey(chatID, page), data, 1*time.Hour)
+	pipe.SAdd(ctx, chatPagesKey(chatID), page)
+	pipe.Expire(ctx, chatPagesKey(chatID), 1*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// deleteChatPages drops every cached history page of a chat
+func deleteChatPages(ctx context.Context, client *redis.Client, chatID string) error {
+	pages, err := client.SMembers(ctx, chatPagesKey(chatID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{chatPagesKey(chatID)}
+	for _, page := range pages {
+		keys = append(keys, chatMessagesKey(chatID, page))
+	}
+	return client.Del(ctx, keys...).Err()
+}
+
+func (c *MessageCache) SetChatMessages(ctx context.Context, chatID, page string, messages []*model.Message) error {
 	data, err := json.Marshal(messages)
 	if err != nil {
 		return err
 	}
-	return c.client.Set(ctx, key, data, 1*time.Hour).Err()
+	return setChatPage(ctx, c.client, chatID, page, data)
 }
 
-func (c *MessageCache) GetChatMessages(ctx context.Context, chatID string) ([]*model.Message, error) {
-	key := fmt.Sprintf("chat:%s:messages", chatID)
-	data, err := c.client.Get(ctx, key).Bytes()
+func (c *MessageCache) DeleteChatMessages(ctx context.Context, chatID string) error {
+	return deleteChatPages(ctx, c.client, chatID)
+}
+
+func chatSummaryKey(chatID string) string {
+	return fmt.Sprintf("chat:%s:summary", chatID)
+}
+
+func (c *MessageCache) SetChatSummary(ctx context.Context, chatID string, summary *model.ChatSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, chatSummaryKey(chatID), data, 1*time.Hour).Err()
+}
+
+func (c *MessageCache) GetChatSummary(ctx context.Context, chatID string) (*model.ChatSummary, error) {
+	data, err := c.client.Get(ctx, chatSummaryKey(chatID)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var summary model.ChatSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (c *MessageCache) DeleteChatSummary(ctx context.Context, chatID string) error {
+	return c.client.Del(ctx, chatSummaryKey(chatID)).Err()
+}
+
+func (c *MessageCache) GetChatMessages(ctx context.Context, chatID, page string) ([]*model.Message, error) {
+	data, err := c.client.Get(ctx, chatMessagesKey(chatID, page)).Bytes()
 	if err != nil {
 		return nil, err
 	}
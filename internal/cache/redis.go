@@ -81,19 +81,17 @@ func (c *RedisCache) DeleteMessage(ctx context.Context, messageID string) error
 	return c.client.Del(ctx, key).Err()
 }
 
-func (c *RedisCache) SetChatMessages(ctx context.Context, chatID string, messages []*model.Message) error {
+func (c *RedisCache) SetChatMessages(ctx context.Context, chatID, page string, messages []*model.Message) error {
 	data, err := json.Marshal(messages)
 	if err != nil {
 		return fmt.Errorf("failed to marshal messages: %w", err)
 	}
 
-	key := fmt.Sprintf("chat:%s:messages", chatID)
-	return c.client.Set(ctx, key, data, 1*time.Hour).Err()
+	return setChatPage(ctx, c.client, chatID, page, data)
 }
 
-func (c *RedisCache) GetChatMessages(ctx context.Context, chatID string) ([]*model.Message, error) {
-	key := fmt.Sprintf("chat:%s:messages", chatID)
-	data, err := c.client.Get(ctx, key).Bytes()
+func (c *RedisCache) GetChatMessages(ctx context.Context, chatID, page string) ([]*model.Message, error) {
+	data, err := c.client.Get(ctx, chatMessagesKey(chatID, page)).Bytes()
 	if err == redis.Nil {
 		return nil, nil
 	}
@@ -108,3 +106,37 @@ func (c *RedisCache) GetChatMessages(ctx context.Context, chatID string) ([]*mod
 
 	return messages, nil
 }
+
+func (c *RedisCache) DeleteChatMessages(ctx context.Context, chatID string) error {
+	return deleteChatPages(ctx, c.client, chatID)
+}
+
+func (c *RedisCache) SetChatSummary(ctx context.Context, chatID string, summary *model.ChatSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat summary: %w", err)
+	}
+
+	return c.client.Set(ctx, chatSummaryKey(chatID), data, 1*time.Hour).Err()
+}
+
+func (c *RedisCache) GetChatSummary(ctx context.Context, chatID string) (*model.ChatSummary, error) {
+	data, err := c.client.Get(ctx, chatSummaryKey(chatID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat summary from cache: %w", err)
+	}
+
+	var summary model.ChatSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chat summary: %w", err)
+	}
+
+	return &summary, nil
+}
+
+func (c *RedisCache) DeleteChatSummary(ctx context.Context, chatID string) error {
+	return c.client.Del(ctx, chatSummaryKey(chatID)).Err()
+}
@@ -0,0 +1,18 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ticketKey marks a WebSocket ticket as redeemed
+func ticketKey(ticketID string) string {
+	return fmt.Sprintf("ws-ticket:%s", ticketID)
+}
+
+// ClaimTicket records a WebSocket ticket as redeemed until it would have
+// expired anyway. It reports false if the ticket was redeemed before.
+func (c *MessageCache) ClaimTicket(ctx context.Context, ticketID string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, ticketKey(ticketID), 1, ttl).Result()
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unreadTTL bounds how long a count can go stale when messages change
+// outside the message service
+const unreadTTL = 1 * time.Hour
+
+// chatUnreadKey holds the unread count of each member of a chat, keyed by
+// user ID, so that a new message invalidates every member at once
+func chatUnreadKey(chatID string) string {
+	return fmt.Sprintf("chat:%s:unread", chatID)
+}
+
+// getUnreadCount returns the cached unread count of a member, or nil on a miss
+func getUnreadCount(ctx context.Context, client *redis.Client, chatID, userID string) (*int64, error) {
+	value, err := client.HGet(ctx, chatUnreadKey(chatID), userID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+func setUnreadCount(ctx context.Context, client *redis.Client, chatID, userID string, count int64) error {
+	key := chatUnreadKey(chatID)
+	pipe := client.TxPipeline()
+	pipe.HSet(ctx, key, userID, count)
+	pipe.Expire(ctx, key, unreadTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *MessageCache) SetUnreadCount(ctx context.Context, chatID, userID string, count int64) error {
+	return setUnreadCount(ctx, c.client, chatID, userID, count)
+}
+
+func (c *MessageCache) GetUnreadCount(ctx context.Context, chatID, userID string) (*int64, error) {
+	return getUnreadCount(ctx, c.client, chatID, userID)
+}
+
+func (c *MessageCache) DeleteUnreadCount(ctx context.Context, chatID, userID string) error {
+	return c.client.HDel(ctx, chatUnreadKey(chatID), userID).Err()
+}
+
+func (c *MessageCache) DeleteUnreadCounts(ctx context.Context, chatID string) error {
+	return c.client.Del(ctx, chatUnreadKey(chatID)).Err()
+}
+
+func (c *RedisCache) SetUnreadCount(ctx context.Context, chatID, userID string, count int64) error {
+	return setUnreadCount(ctx, c.client, chatID, userID, count)
+}
+
+func (c *RedisCache) GetUnreadCount(ctx context.Context, chatID, userID string) (*int64, error) {
+	count, err := getUnreadCount(ctx, c.client, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unread count from cache: %w", err)
+	}
+	return count, nil
+}
+
+func (c *RedisCache) DeleteUnreadCount(ctx context.Context, chatID, userID string) error {
+	return c.client.HDel(ctx, chatUnreadKey(chatID), userID).Err()
+}
+
+func (c *RedisCache) DeleteUnreadCounts(ctx context.Context, chatID string) error {
+	return c.client.Del(ctx, chatUnreadKey(chatID)).Err()
+}
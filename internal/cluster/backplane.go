@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Envelope is a WebSocket frame relayed between server nodes
+type Envelope struct {
+	NodeID  string          `json:"node_id"`
+	ChatID  string          `json:"chat_id,omitempty"`  // Empty for frames sent to every client
+	Seq     int64           `json:"seq,omitempty"`      // Message sequence for chat frames
+	UserIDs []string        `json:"user_ids,omitempty"` // Recipients of frames sent to specific users
+	Payload json.RawMessage `json:"payload"`
+
+	// Member of ChatID whose subscriptions end once the frame is delivered
+	Unsubscribe string `json:"unsubscribe,omitempty"`
+}
+
+// Handler is called for every envelope received from the backplane
+type Handler func(env Envelope)
+
+// Backplane relays frames between server nodes so clients connected to
+// different nodes see the same traffic
+type Backplane interface {
+	// Publish sends an envelope to every node, including this one
+	Publish(ctx context.Context, env Envelope) error
+	// Subscribe delivers received envelopes to handler until ctx is done
+	Subscribe(ctx context.Context, handler Handler) error
+	// Close releases the resources held by the backplane
+	Close() error
+}
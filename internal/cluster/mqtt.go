@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"rtcs/internal/mqtt"
+)
+
+// MQTTBackplane implements Backplane on top of an MQTT broker. Its topic
+// is reserved for the broker's server account, so nodes must connect with it.
+type MQTTBackplane struct {
+	broker      string
+	nodeID      string
+	credentials mqtt.Credentials
+	qos         byte
+	publisher   *mqtt.Publisher
+	subscriber  *mqtt.Subscriber
+}
+
+// NewMQTTBackplane connects to the broker and relays envelopes with the given QoS
+func NewMQTTBackplane(broker, nodeID string, credentials mqtt.Credentials, qos byte) (*MQTTBackplane, error) {
+	publisher, err := mqtt.NewPublisher(broker, nodeID+"-cluster-pub", credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MQTTBackplane{
+		broker:      broker,
+		nodeID:      nodeID,
+		credentials: credentials,
+		qos:         qos,
+		publisher:   publisher,
+	}, nil
+}
+
+// Publish sends an envelope to every subscribed node
+func (b *MQTTBackplane) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return b.publisher.PublishWithOptions(mqtt.ClusterTopic, data, b.qos, false)
+}
+
+// Subscribe starts delivering envelopes to handler until ctx is done
+func (b *MQTTBackplane) Subscribe(ctx context.Context, handler Handler) error {
+	subscriber, err := mqtt.NewSubscriber(b.broker, b.nodeID+"-cluster-sub", b.credentials, func(topic string, payload []byte) {
+		var env Envelope
+		if err := json.Unmarshal(payload, &env); err != nil {
+			log.Printf("[ERROR] Failed to decode backplane envelope: %v", err)
+			return
+		}
+		handler(env)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := subscriber.SubscribeWithQoS(mqtt.ClusterTopic, b.qos); err != nil {
+		subscriber.Disconnect()
+		return err
+	}
+	b.subscriber = subscriber
+
+	go func() {
+		<-ctx.Done()
+		subscriber.Disconnect()
+	}()
+
+	return nil
+}
+
+// Close disconnects from the broker
+func (b *MQTTBackplane) Close() error {
+	if b.subscriber != nil {
+		b.subscriber.Disconnect()
+	}
+	b.publisher.Disconnect()
+	return nil
+}
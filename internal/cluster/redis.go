@@ -0,0 +1,78 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisChannel is the pub/sub channel used to relay WebSocket frames
+const DefaultRedisChannel = "rtcs:ws:cluster"
+
+// RedisBackplane implements Backplane using Redis pub/sub
+type RedisBackplane struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisBackplane creates a backplane on the given pub/sub channel
+func NewRedisBackplane(client *redis.Client, channel string) *RedisBackplane {
+	return &RedisBackplane{
+		client:  client,
+		channel: channel,
+	}
+}
+
+// Publish sends an envelope to every subscribed node
+func (b *RedisBackplane) Publish(ctx context.Context, env Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe starts delivering envelopes to handler in the background
+func (b *RedisBackplane) Subscribe(ctx context.Context, handler Handler) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+
+	// Wait for the subscription to be confirmed so no frames are missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", b.channel, err)
+	}
+
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var env Envelope
+				if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+					log.Printf("[ERROR] Failed to decode backplane envelope: %v", err)
+					continue
+				}
+				handler(env)
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close is a no-op; the Redis client is owned by the caller
+func (b *RedisBackplane) Close() error {
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package imaging decodes uploaded images and scales them into thumbnails
+// using only the standard library
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// Formats accepted by Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels bounds the decoded size of an image, so a small file cannot
+// expand into gigabytes of pixels
+const MaxPixels = 40_000_000
+
+// thumbnailQuality is the JPEG quality of thumbnails
+const thumbnailQuality = 85
+
+var (
+	// ErrUnsupportedImage is returned for content that is not a JPEG, PNG
+	// or GIF image
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	// ErrImageTooLarge is returned for images over MaxPixels
+	ErrImageTooLarge = errors.New("image dimensions too large")
+)
+
+// Decode reads a JPEG, PNG or GIF image after checking its dimensions
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+// Square crops the centre square of an image and scales it to size×size
+func Square(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	return scale(src, image.Rect(x0, y0, x0+side, y0+side), size, size)
+}
+
+// Fit scales an image down to fit within maxSize×maxSize, keeping its
+// aspect ratio; smaller images keep their size
+func Fit(src image.Image, maxSize int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			h = max(1, h*maxSize/w)
+			w = maxSize
+		} else {
+			w = max(1, w*maxSize/h)
+			h = maxSize
+		}
+	}
+	return scale(src, b, w, h)
+}
+
+// scale resamples the area r of src to w×h. Each destination pixel averages
+// the source pixels it covers, which keeps downscaled images free of the
+// aliasing nearest-neighbour sampling produces.
+func scale(src image.Image, r image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for dy := 0; dy < h; dy++ {
+		sy0 := r.Min.Y + dy*r.Dy()/h
+		sy1 := max(sy0+1, r.Min.Y+(dy+1)*r.Dy()/h)
+		for dx := 0; dx < w; dx++ {
+			sx0 := r.Min.X + dx*r.Dx()/w
+			sx1 := max(sx0+1, r.Min.X+(dx+1)*r.Dx()/w)
+
+			var sr, sg, sb, sa, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					sr += uint64(cr)
+					sg += uint64(cg)
+					sb += uint64(cb)
+					sa += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(sr / n >> 8),
+				G: uint8(sg / n >> 8),
+				B: uint8(sb / n >> 8),
+				A: uint8(sa / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG writes a thumbnail as JPEG. JPEG has no transparency, so the
+// image is flattened onto white first.
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: thumbnailQuality})
+}
@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestDecode(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	img, err := Decode(encodePNG(t, src))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := img.Bounds().Size(); got != (image.Point{40, 20}) {
+		t.Errorf("decoded size = %v, want 40x20", got)
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("not an image"))); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("Decode(text) error = %v, want ErrUnsupportedImage", err)
+	}
+
+	// Only the header is read for an oversized image
+	huge := encodePNG(t, image.NewGray(image.Rect(0, 0, 10000, 5000)))
+	if _, err := Decode(huge); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Decode(huge) error = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestSquare(t *testing.T) {
+	// A wide image whose outer thirds are red and centre is blue
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	thumb := Square(src, 32)
+	if got := thumb.Bounds().Size(); got != (image.Point{32, 32}) {
+		t.Fatalf("Square() size = %v, want 32x32", got)
+	}
+	// The centre crop keeps only the blue part
+	for _, p := range []image.Point{{0, 0}, {31, 31}, {16, 16}} {
+		if c := thumb.RGBAAt(p.X, p.Y); c != (color.RGBA{B: 255, A: 255}) {
+			t.Errorf("pixel %v = %v, want blue", p, c)
+		}
+	}
+
+	// Smaller images are scaled up to the requested size
+	if got := Square(image.NewRGBA(image.Rect(0, 0, 10, 8)), 64).Bounds().Size(); got != (image.Point{64, 64}) {
+		t.Errorf("Square(small) size = %v, want 64x64", got)
+	}
+}
+
+func TestSquareAveragesPixels(t *testing.T) {
+	// Alternating black and white columns average to grey
+	src := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x += 2 {
+			src.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	thumb := Square(src, 8)
+	c := thumb.RGBAAt(4, 4)
+	if c.R < 120 || c.R > 135 {
+		t.Errorf("averaged pixel = %v, want mid grey", c)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		want image.Point
+	}{
+		{"landscape", 800, 400, image.Point{320, 160}},
+		{"portrait", 300, 900, image.Point{106, 320}},
+		{"small", 100, 50, image.Point{100, 50}},
+		{"thin", 5000, 2, image.Point{320, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fit(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), 320).Bounds().Size()
+			if got != tt.want {
+				t.Errorf("Fit() size = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	// Transparent pixels are flattened onto white
+	var buf bytes.Buffer
+	if err := EncodeJPEG(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16))); err != nil {
+		t.Fatalf("EncodeJPEG() error = %v", err)
+	}
+
+	img, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode JPEG: %v", err)
+	}
+	r, g, b, _ := img.At(8, 8).RGBA()
+	if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("flattened pixel = (%d, %d, %d), want white", r>>8, g>>8, b>>8)
+	}
+}
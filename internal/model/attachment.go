@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attachment is a file uploaded to a chat. It is stored in the blob store
+// under StorageKey and belongs to a message once that message is sent.
+type Attachment struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ChatID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"chat_id"`
+	MessageID   *uuid.UUID `gorm:"type:uuid;index" json:"message_id,omitempty"` // Unset until sent with a message
+	UploaderID  uuid.UUID  `gorm:"type:uuid;not null" json:"uploader_id"`
+	FileName    string     `gorm:"type:varchar(255);not null" json:"file_name"`
+	ContentType string     `gorm:"type:varchar(255);not null" json:"content_type"` // Sniffed from the content
+	Size        int64      `gorm:"not null" json:"size"`
+	StorageKey  string     `gorm:"type:varchar(512);not null" json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// Image attachments get a downscaled JPEG preview; the dimensions are
+	// those of the original image
+	ThumbnailKey string `gorm:"type:varchar(512)" json:"-"`
+	Width        int    `gorm:"not null;default:0" json:"width,omitempty"`
+	Height       int    `gorm:"not null;default:0" json:"height,omitempty"`
+
+	// URL downloads the file and ThumbnailURL its preview; they require the
+	// same authentication as the API
+	URL          string `gorm:"-" json:"url"`
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url,omitempty"`
+}
+
+// AfterFind sets the download URLs of loaded attachments
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	a.URL = "/attachments/" + a.ID.String()
+	if a.ThumbnailKey != "" {
+		a.ThumbnailURL = a.URL + "/thumbnail"
+	}
+	return nil
+}
+
+// AfterCreate sets the download URLs of new attachments
+func (a *Attachment) AfterCreate(tx *gorm.DB) error {
+	return a.AfterFind(tx)
+}
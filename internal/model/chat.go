@@ -4,23 +4,156 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Chat represents a chat room
 type Chat struct {
 	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
 	Name      string     `gorm:"type:varchar(255);not null" json:"name"`
+	LastSeq   int64      `gorm:"not null;default:0" json:"last_seq"` // Sequence of the latest message
 	CreatedAt time.Time  `gorm:"index" json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
 	Users     []User     `gorm:"many2many:chat_users;" json:"users,omitempty"`
+
+	// Largest attachment accepted in the chat in bytes; zero uses the
+	// server default
+	MaxAttachmentSize int64 `gorm:"not null;default:0" json:"max_attachment_size,omitempty"`
+
+	// User who created the chat; ownership may since have been transferred
+	CreatedBy *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+
+	// Who may join the chat without an invite
+	Visibility ChatVisibility `gorm:"type:varchar(16);not null;default:public" json:"visibility"`
+
+	// Kind of chat; direct chats between two users carry the canonical key
+	// of the pair, so there is one per pair
+	Kind  ChatKind `gorm:"type:varchar(16);not null;default:channel" json:"kind"`
+	DMKey *string  `gorm:"type:varchar(80);uniqueIndex" json:"-"`
+}
+
+// ChatKind tells channels apart from direct messages
+type ChatKind string
+
+// Chat kinds
+const (
+	KindChannel ChatKind = "channel" // Named chat run by its owner and admins
+	KindDirect  ChatKind = "direct"  // Private chat between two users
+	KindGroup   ChatKind = "group"   // Private chat between a few users
+)
+
+// DirectMessageKey returns the key shared by the direct chats of a pair of
+// users, whichever of them opens it
+func DirectMessageKey(a, b uuid.UUID) string {
+	first, second := a.String(), b.String()
+	if second < first {
+		first, second = second, first
+	}
+	return first + ":" + second
+}
+
+// ChatVisibility decides who may join a chat
+type ChatVisibility string
+
+// Chat visibilities
+const (
+	VisibilityPublic  ChatVisibility = "public"  // Anyone may join
+	VisibilityPrivate ChatVisibility = "private" // Joined only through invites
+)
+
+// Valid reports whether the visibility is one of the known ones
+func (v ChatVisibility) Valid() bool {
+	return v == VisibilityPublic || v == VisibilityPrivate
 }
 
 // ChatUser represents a user's membership in a chat
 type ChatUser struct {
-	ChatID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"chat_id"`
-	UserID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
-	JoinedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"joined_at"`
-	Chat     *Chat     `gorm:"foreignKey:ChatID" json:"-"`
-	User     *User     `gorm:"foreignKey:UserID" json:"-"`
+	ChatID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"chat_id"`
+	UserID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	JoinedAt          time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"joined_at"`
+	LastDeliveredSeq  int64      `gorm:"not null;default:0" json:"last_delivered_seq"`
+	LastReadSeq       int64      `gorm:"not null;default:0" json:"last_read_seq"`
+	LastReadMessageID *uuid.UUID `gorm:"type:uuid" json:"last_read_message_id,omitempty"`
+	Chat              *Chat      `gorm:"foreignKey:ChatID" json:"-"`
+	User              *User      `gorm:"foreignKey:UserID" json:"-"`
+
+	// Role decides what the member may do in the chat
+	Role ChatRole `gorm:"type:varchar(16);not null;default:member" json:"role"`
+	// Member who added this one, directly or through an invite; nil for
+	// members who created or joined the chat themselves
+	InvitedBy *uuid.UUID `gorm:"type:uuid" json:"invited_by,omitempty"`
+}
+
+// BeforeCreate starts new members at the end of the chat's history, so that
+// messages from before they joined do not count as unread
+func (cu *ChatUser) BeforeCreate(tx *gorm.DB) error {
+	if cu.LastReadSeq != 0 {
+		return nil
+	}
+	var lastSeq int64
+	if err := tx.Session(&gorm.Session{NewDB: true}).
+		Model(&Chat{}).
+		Select("last_seq").
+		Where("id = ?", cu.ChatID).
+		Scan(&lastSeq).Error; err != nil {
+		return err
+	}
+	cu.LastReadSeq = lastSeq
+	if cu.LastDeliveredSeq < lastSeq {
+		cu.LastDeliveredSeq = lastSeq
+	}
+	return nil
+}
+
+// ChatMember is a member of a chat as listed to the other members
+type ChatMember struct {
+	UserID    uuid.UUID    `json:"user_id"`
+	Role      ChatRole     `json:"role"`
+	JoinedAt  time.Time    `json:"joined_at"`
+	InvitedBy *uuid.UUID   `json:"invited_by,omitempty"`
+	Status    string       `json:"status"` // "online" or "offline"
+	Profile   *UserProfile `json:"profile,omitempty"`
+}
+
+// ReadPosition is how far a member has received and read a chat
+type ReadPosition struct {
+	UserID            uuid.UUID  `json:"user_id"`
+	LastDeliveredSeq  int64      `json:"last_delivered_seq"`
+	LastReadSeq       int64      `json:"last_read_seq"`
+	LastReadMessageID *uuid.UUID `json:"last_read_message_id,omitempty"`
+}
+
+// ReadPosition returns the member's delivery and read position
+func (cu *ChatUser) ReadPosition() *ReadPosition {
+	return &ReadPosition{
+		UserID:            cu.UserID,
+		LastDeliveredSeq:  cu.LastDeliveredSeq,
+		LastReadSeq:       cu.LastReadSeq,
+		LastReadMessageID: cu.LastReadMessageID,
+	}
+}
+
+// MessagePreview is a short form of a chat's latest message
+type MessagePreview struct {
+	ID        uuid.UUID `json:"id"`
+	SenderID  uuid.UUID `json:"sender_id"`
+	Text      string    `json:"text"`
+	Seq       int64     `json:"seq"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChatSummary is the activity of a chat, shared by all of its members
+type ChatSummary struct {
+	LastMessage    *MessagePreview `json:"last_message,omitempty"`
+	LastActivityAt time.Time       `json:"last_activity_at"`
+}
+
+// ChatOverview is a chat as listed for one member
+type ChatOverview struct {
+	*Chat
+	UnreadCount    int64           `json:"unread_count"`
+	LastReadSeq    int64           `json:"last_read_seq"`
+	LastMessage    *MessagePreview `json:"last_message,omitempty"`
+	LastActivityAt time.Time       `json:"last_activity_at"`
 }
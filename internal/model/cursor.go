@@ -0,0 +1,121 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for history cursors the server did not issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MessageCursor marks a position in a chat's history. Messages are ordered
+// by creation time, with the ID breaking ties between messages created in
+// the same microsecond.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// CursorAt returns the cursor positioned at a message
+func CursorAt(message *Message) MessageCursor {
+	return MessageCursor{CreatedAt: message.CreatedAt, ID: message.ID}
+}
+
+// Encode returns the opaque form of the cursor handed to clients. Times are
+// kept to the microsecond, the precision of the database.
+func (c MessageCursor) Encode() string {
+	return encodeCursor(c.CreatedAt, c.ID)
+}
+
+// ParseMessageCursor decodes a cursor produced by Encode
+func ParseMessageCursor(s string) (MessageCursor, error) {
+	at, id, err := parseCursor(s)
+	if err != nil {
+		return MessageCursor{}, err
+	}
+	return MessageCursor{CreatedAt: at, ID: id}, nil
+}
+
+// MemberCursor marks a position in a chat's member list. Members are
+// ordered by when they joined, with the user ID breaking ties.
+type MemberCursor struct {
+	JoinedAt time.Time
+	UserID   uuid.UUID
+}
+
+// MemberCursorAt returns the cursor positioned at a member
+func MemberCursorAt(member *ChatMember) MemberCursor {
+	return MemberCursor{JoinedAt: member.JoinedAt, UserID: member.UserID}
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c MemberCursor) Encode() string {
+	return encodeCursor(c.JoinedAt, c.UserID)
+}
+
+// ParseMemberCursor decodes a cursor produced by Encode
+func ParseMemberCursor(s string) (MemberCursor, error) {
+	at, id, err := parseCursor(s)
+	if err != nil {
+		return MemberCursor{}, err
+	}
+	return MemberCursor{JoinedAt: at, UserID: id}, nil
+}
+
+// MemberQuery selects a page of a chat's members, in the order they joined
+type MemberQuery struct {
+	After *MemberCursor
+	Limit int
+}
+
+func encodeCursor(at time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(at.UnixMicro(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseCursor(s string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	micros, idStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	usec, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	return time.UnixMicro(usec), id, nil
+}
+
+// HistoryQuery selects a page of a chat's top-level messages. Without a
+// cursor it returns the newest messages; Before pages towards older messages
+// and After towards newer ones. Pages are always ordered newest first.
+type HistoryQuery struct {
+	Before *MessageCursor
+	After  *MessageCursor
+	Limit  int
+}
+
+// Key identifies the page for caching
+func (q HistoryQuery) Key() string {
+	limit := strconv.Itoa(q.Limit)
+	switch {
+	case q.After != nil:
+		return "after:" + q.After.Encode() + ":" + limit
+	case q.Before != nil:
+		return "before:" + q.Before.Encode() + ":" + limit
+	default:
+		return "latest:" + limit
+	}
+}
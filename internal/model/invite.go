@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatInvite lets whoever holds its token join a chat, private ones
+// included, until it expires, is used up or is revoked. Only a hash of the
+// token is stored.
+type ChatInvite struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ChatID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"chat_id"`
+	TokenHash string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	MaxUses   int        `gorm:"not null" json:"max_uses"`
+	Uses      int        `gorm:"not null;default:0" json:"uses"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy *uuid.UUID `gorm:"type:uuid" json:"revoked_by,omitempty"`
+
+	// Users who joined through the invite, the audit of who invited whom
+	Accepted []ChatInviteUse `gorm:"foreignKey:InviteID" json:"accepted,omitempty"`
+	// The token itself, only known when the invite is created
+	Token string `gorm:"-" json:"token,omitempty"`
+}
+
+// ChatInviteUse records a user joining a chat through an invite
+type ChatInviteUse struct {
+	InviteID uuid.UUID `gorm:"type:uuid;primaryKey" json:"invite_id"`
+	UserID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	UsedAt   time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"used_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// MessageMention records that a message mentions a chat member by name.
+// Mentions of the whole chat are flagged on the message instead.
+type MessageMention struct {
+	MessageID uuid.UUID `gorm:"type:uuid;primaryKey" json:"message_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"user_id"`
+	Username  string    `gorm:"type:varchar(255);not null" json:"username"` // As written in the text
+}
+
+// InboxMention is a message that mentions a user, as listed in their inbox
+type InboxMention struct {
+	Message *Message `json:"message"`
+	Unread  bool     `json:"unread"` // Sent after the user's read position in the chat
+}
+
+// MentionQuery selects a page of a user's mention inbox, newest first
+type MentionQuery struct {
+	UnreadOnly bool
+	Before     *MessageCursor
+	Limit      int
+}
@@ -6,12 +6,47 @@ import (
 	"github.com/google/uuid"
 )
 
+// DeletedMessageText replaces the text of deleted messages
+const DeletedMessageText = "message deleted"
+
+// Message is a chat message. Deleted messages stay in place as tombstones
+// with DeletedAt set and their text replaced by DeletedMessageText.
 type Message struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid();index:idx_messages_chat_history,priority:3" json:"id"`
+	ChatID    uuid.UUID  `gorm:"type:uuid;index;uniqueIndex:idx_messages_chat_id_seq,priority:1;index:idx_messages_chat_history,priority:1,where:parent_id IS NULL" json:"chat_id"`
+	Seq       int64      `gorm:"not null;default:0;uniqueIndex:idx_messages_chat_id_seq,priority:2" json:"seq"` // Per-chat sequence, assigned on save
+	SenderID  uuid.UUID  `gorm:"type:uuid;index" json:"sender_id"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id,omitempty"` // Thread root for replies
+	Text      string     `gorm:"type:text;not null" json:"text"`
+	CreatedAt time.Time  `gorm:"index;index:idx_messages_chat_history,priority:2" json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"` // Set once the text has been changed
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	DeletedBy *uuid.UUID `gorm:"type:uuid" json:"deleted_by,omitempty"`
+	// Optional moderator note on why the message was removed
+	DeleteReason string `gorm:"type:text" json:"delete_reason,omitempty"`
+
+	// Thread activity, maintained on thread roots as replies are saved
+	ReplyCount  int64      `gorm:"not null;default:0" json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+
+	// Files sent with the message
+	Attachments []Attachment `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
+
+	// Members mentioned by name, and whether @channel mentioned everyone;
+	// both are resolved when the message is sent
+	Mentions        []MessageMention `gorm:"foreignKey:MessageID" json:"mentions,omitempty"`
+	MentionsChannel bool             `gorm:"not null;default:false" json:"mentions_channel,omitempty"`
+
+	// Reactions as seen by the requesting user; not stored on the row
+	Reactions []ReactionSummary `gorm:"-" json:"reactions,omitempty"`
+}
+
+// MessageRevision is a prior text of an edited message
+type MessageRevision struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	ChatID    uuid.UUID `gorm:"type:uuid;index" json:"chat_id"`
-	SenderID  uuid.UUID `gorm:"type:uuid;index" json:"sender_id"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;index" json:"message_id"`
 	Text      string    `gorm:"type:text;not null" json:"text"`
-	CreatedAt time.Time `gorm:"index" json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+	EditedBy  uuid.UUID `gorm:"type:uuid;not null" json:"edited_by"`
+	CreatedAt time.Time `json:"created_at"` // When the text was replaced
 }
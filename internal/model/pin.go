@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatPin is a message pinned to the top of a chat
+type ChatPin struct {
+	ChatID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"chat_id"`
+	MessageID uuid.UUID `gorm:"type:uuid;primaryKey" json:"message_id"`
+	PinnedBy  uuid.UUID `gorm:"type:uuid;not null" json:"pinned_by"`
+	PinnedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"pinned_at"`
+	Message   *Message  `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+}
@@ -0,0 +1,30 @@
+package model
+
+// ChatRole is a member's standing in a chat
+type ChatRole string
+
+// Chat roles, from most to least privileged. Every chat has one owner.
+const (
+	RoleOwner    ChatRole = "owner"
+	RoleAdmin    ChatRole = "admin"
+	RoleMember   ChatRole = "member"
+	RoleReadOnly ChatRole = "read_only"
+)
+
+// roleRanks orders the roles; unknown roles rank below all of them
+var roleRanks = map[ChatRole]int{
+	RoleOwner:    4,
+	RoleAdmin:    3,
+	RoleMember:   2,
+	RoleReadOnly: 1,
+}
+
+// Valid reports whether the role is one of the defined roles
+func (r ChatRole) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Outranks reports whether the role is more privileged than other
+func (r ChatRole) Outranks(other ChatRole) bool {
+	return roleRanks[r] > roleRanks[other]
+}
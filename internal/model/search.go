@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchQuery selects messages matching a full-text query. Text uses web
+// search syntax: "quoted phrases", OR and -excluded words. The optional
+// filters narrow the results further.
+type SearchQuery struct {
+	Text     string
+	ChatID   *uuid.UUID
+	SenderID *uuid.UUID
+	From     *time.Time // Sent at or after
+	To       *time.Time // Sent before
+	Before   *MessageCursor
+	Limit    int
+}
+
+// SearchResult is a matching message with the matched words highlighted in
+// an HTML-escaped snippet of its text
+type SearchResult struct {
+	Message *Message `json:"message"`
+	Snippet string   `json:"snippet"`
+}
@@ -0,0 +1,18 @@
+package mqtt
+
+import mqtt "github.com/eclipse/paho.mqtt.golang"
+
+// Credentials authenticate a client to the broker. The server connects with
+// the broker's server account, which alone may use the cluster topics and
+// publish chat messages.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+func (c Credentials) apply(opts *mqtt.ClientOptions) {
+	if c.Username != "" {
+		opts.SetUsername(c.Username)
+		opts.SetPassword(c.Password)
+	}
+}
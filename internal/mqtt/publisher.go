@@ -10,12 +10,13 @@ type Publisher struct {
 	client mqtt.Client
 }
 
-func NewPublisher(broker string, clientID string) (*Publisher, error) {
+func NewPublisher(broker string, clientID string, credentials Credentials) (*Publisher, error) {
 	opts := mqtt.NewClientOptions().
 		AddBroker(broker).
 		SetClientID(clientID).
 		SetCleanSession(true).
 		SetAutoReconnect(true)
+	credentials.apply(opts)
 
 	client := mqtt.NewClient(opts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
@@ -28,7 +29,12 @@ func NewPublisher(broker string, clientID string) (*Publisher, error) {
 }
 
 func (p *Publisher) Publish(topic string, message []byte) error {
-	token := p.client.Publish(topic, 0, false, message)
+	return p.PublishWithOptions(topic, message, 0, false)
+}
+
+// PublishWithOptions publishes a message with the given QoS level and retained flag
+func (p *Publisher) PublishWithOptions(topic string, message []byte, qos byte, retained bool) error {
+	token := p.client.Publish(topic, qos, retained, message)
 	if token.Wait() && token.Error() != nil {
 		return fmt.Errorf("error publishing message: %v", token.Error())
 	}
@@ -3,6 +3,7 @@ package mqtt
 import (
 	"fmt"
 	"log"
+	"sync"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
@@ -12,41 +13,73 @@ type MessageHandler func(topic string, payload []byte)
 type Subscriber struct {
 	client         mqtt.Client
 	messageHandler MessageHandler
+	topics         map[string]byte // Subscribed topics and their QoS, restored on reconnect
+	topicsMux      sync.Mutex
 }
 
-func NewSubscriber(broker string, clientID string, messageHandler MessageHandler) (*Subscriber, error) {
+func NewSubscriber(broker string, clientID string, credentials Credentials, messageHandler MessageHandler) (*Subscriber, error) {
+	s := &Subscriber{
+		messageHandler: messageHandler,
+		topics:         make(map[string]byte),
+	}
+
 	opts := mqtt.NewClientOptions().
 		AddBroker(broker).
 		SetClientID(clientID).
 		SetCleanSession(true).
-		SetAutoReconnect(true)
+		SetAutoReconnect(true).
+		SetOnConnectHandler(s.resubscribe)
+	credentials.apply(opts)
 
 	client := mqtt.NewClient(opts)
+	s.client = client
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
 		return nil, fmt.Errorf("error connecting to broker: %v", token.Error())
 	}
 
-	return &Subscriber{
-		client:         client,
-		messageHandler: messageHandler,
-	}, nil
+	return s, nil
 }
 
 func (s *Subscriber) Subscribe(topic string) error {
-	token := s.client.Subscribe(topic, 0, func(client mqtt.Client, msg mqtt.Message) {
-		if s.messageHandler != nil {
-			s.messageHandler(msg.Topic(), msg.Payload())
-		}
-	})
+	return s.SubscribeWithQoS(topic, 0)
+}
+
+// SubscribeWithQoS subscribes to a topic filter with the given QoS level
+func (s *Subscriber) SubscribeWithQoS(topic string, qos byte) error {
+	token := s.client.Subscribe(topic, qos, s.handle)
 
 	if token.Wait() && token.Error() != nil {
 		return fmt.Errorf("error subscribing to topic %s: %v", topic, token.Error())
 	}
 
+	s.topicsMux.Lock()
+	s.topics[topic] = qos
+	s.topicsMux.Unlock()
+
 	log.Printf("Subscribed to topic: %s\n", topic)
 	return nil
 }
 
+func (s *Subscriber) handle(client mqtt.Client, msg mqtt.Message) {
+	if s.messageHandler != nil {
+		s.messageHandler(msg.Topic(), msg.Payload())
+	}
+}
+
+// resubscribe restores subscriptions after a reconnect, since clean
+// sessions drop them on the broker
+func (s *Subscriber) resubscribe(client mqtt.Client) {
+	s.topicsMux.Lock()
+	defer s.topicsMux.Unlock()
+
+	for topic, qos := range s.topics {
+		token := client.Subscribe(topic, qos, s.handle)
+		if token.Wait() && token.Error() != nil {
+			log.Printf("Error resubscribing to topic %s: %v\n", topic, token.Error())
+		}
+	}
+}
+
 func (s *Subscriber) Disconnect() {
 	if s.client.IsConnected() {
 		s.client.Disconnect(250)
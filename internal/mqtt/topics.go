@@ -0,0 +1,57 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topic layout shared by the server and MQTT clients
+const (
+	// ClusterTopic carries WebSocket frames between server nodes
+	ClusterTopic = "rtcs/cluster/frames"
+	// ChatSendWildcard matches the topics clients publish new chat messages to
+	ChatSendWildcard = "rtcs/chats/+/send/+"
+	// PresencePrefix starts every presence topic
+	PresencePrefix = "rtcs/presence/"
+)
+
+// ChatMessagesTopic is where the server publishes the messages of a chat
+func ChatMessagesTopic(chatID string) string {
+	return fmt.Sprintf("rtcs/chats/%s/messages", chatID)
+}
+
+// ChatSendTopic is where a user publishes new messages for a chat. The
+// broker only lets a client publish to topics carrying its own user ID.
+func ChatSendTopic(chatID, userID string) string {
+	return fmt.Sprintf("rtcs/chats/%s/send/%s", chatID, userID)
+}
+
+// PresenceTopic is where the server publishes a user's online status
+func PresenceTopic(userID string) string {
+	return PresencePrefix + userID
+}
+
+// SharedTopic wraps a topic filter in a shared subscription so each message
+// is delivered to only one subscriber of the group
+func SharedTopic(group, topic string) string {
+	return fmt.Sprintf("$share/%s/%s", group, topic)
+}
+
+// ParseChatTopic extracts the chat ID from a rtcs/chats/{id}/messages topic
+func ParseChatTopic(topic string) (string, bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "rtcs" || parts[1] != "chats" || parts[2] == "" || parts[3] != "messages" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// ParseSendTopic extracts the chat and user IDs from a
+// rtcs/chats/{id}/send/{user} topic
+func ParseSendTopic(topic string) (chatID, userID string, ok bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "rtcs" || parts[1] != "chats" || parts[2] == "" || parts[3] != "send" || parts[4] == "" {
+		return "", "", false
+	}
+	return parts[2], parts[4], true
+}
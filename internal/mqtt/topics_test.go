@@ -0,0 +1,47 @@
+package mqtt
+
+import "testing"
+
+func TestParseChatTopic(t *testing.T) {
+	tests := []struct {
+		topic  string
+		chatID string
+		ok     bool
+	}{
+		{ChatMessagesTopic("abc"), "abc", true},
+		{"rtcs/chats//messages", "", false},
+		{ChatSendTopic("abc", "me"), "", false},
+		{PresenceTopic("abc"), "", false},
+		{"rtcs/chats/abc", "", false},
+	}
+
+	for _, tt := range tests {
+		chatID, ok := ParseChatTopic(tt.topic)
+		if chatID != tt.chatID || ok != tt.ok {
+			t.Errorf("ParseChatTopic(%q) = (%q, %v), want (%q, %v)", tt.topic, chatID, ok, tt.chatID, tt.ok)
+		}
+	}
+}
+
+func TestParseSendTopic(t *testing.T) {
+	tests := []struct {
+		topic  string
+		chatID string
+		userID string
+		ok     bool
+	}{
+		{ChatSendTopic("abc", "me"), "abc", "me", true},
+		{"rtcs/chats/abc/send", "", "", false},
+		{"rtcs/chats//send/me", "", "", false},
+		{"rtcs/chats/abc/send/", "", "", false},
+		{ChatMessagesTopic("abc"), "", "", false},
+		{"rtcs/chats/abc/send/me/extra", "", "", false},
+	}
+
+	for _, tt := range tests {
+		chatID, userID, ok := ParseSendTopic(tt.topic)
+		if chatID != tt.chatID || userID != tt.userID || ok != tt.ok {
+			t.Errorf("ParseSendTopic(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.topic, chatID, userID, ok, tt.chatID, tt.userID, tt.ok)
+		}
+	}
+}
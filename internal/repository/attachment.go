@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateAttachment stores the metadata of an uploaded file
+func (r *MessageRepository) CreateAttachment(ctx context.Context, attachment *model.Attachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+// GetAttachment retrieves an attachment by ID, or nil if it does not exist
+func (r *MessageRepository) GetAttachment(ctx context.Context, attachmentID uuid.UUID) (*model.Attachment, error) {
+	var attachment model.Attachment
+	err := r.db.WithContext(ctx).First(&attachment, "id = ?", attachmentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// GetAttachments retrieves the attachments with the given IDs; missing ones
+// are left out
+func (r *MessageRepository) GetAttachments(ctx context.Context, attachmentIDs []uuid.UUID) ([]*model.Attachment, error) {
+	var attachments []*model.Attachment
+	err := r.db.WithContext(ctx).Where("id IN ?", attachmentIDs).Find(&attachments).Error
+	return attachments, err
+}
+
+// claimAttachments links message.Attachments to a new message. Each must
+// have been uploaded to the message's chat by its sender and not be sent
+// yet; tx must be a transaction.
+func claimAttachments(tx *gorm.DB, message *model.Message) error {
+	if len(message.Attachments) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		ids = append(ids, attachment.ID)
+	}
+	result := tx.Model(&model.Attachment{}).
+		Where("id IN ? AND chat_id = ? AND uploader_id = ? AND message_id IS NULL", ids, message.ChatID, message.SenderID).
+		Update("message_id", message.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return fmt.Errorf("attachments of message %s are no longer available", message.ID)
+	}
+
+	for i := range message.Attachments {
+		message.Attachments[i].MessageID = &message.ID
+	}
+	return nil
+}
@@ -9,8 +9,13 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrMembershipChanged is returned when a membership an update relies on
+// no longer holds
+var ErrMembershipChanged = errors.New("chat membership changed")
+
 type chatRepository struct {
 	db *gorm.DB
 }
@@ -20,7 +25,9 @@ func NewChatRepository(db *gorm.DB) *chatRepository {
 }
 
 func (r *chatRepository) CreateMessage(ctx context.Context, message *model.Message) error {
-	return r.db.WithContext(ctx).Create(message).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createSequencedMessage(tx, message)
+	})
 }
 
 func (r *chatRepository) GetMessage(ctx context.Context, id uuid.UUID) (*model.Message, error) {
@@ -32,19 +39,14 @@ func (r *chatRepository) GetMessage(ctx context.Context, id uuid.UUID) (*model.M
 	return &message, err
 }
 
-func (r *chatRepository) ListMessages(ctx context.Context, chatID uuid.UUID, limit, offset int) ([]*model.Message, error) {
-	var messages []*model.Message
-	err := r.db.WithContext(ctx).
-		Where("chat_id = ?", chatID).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error
-	return messages, err
+func (r *chatRepository) ListMessages(ctx context.Context, chatID uuid.UUID, query model.HistoryQuery) ([]*model.Message, error) {
+	return messagePage(r.db.WithContext(ctx), chatID, query)
 }
 
 func (r *chatRepository) DeleteMessage(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Message{}, "id = ?", id).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tombstoneMessage(tx, id, nil, "", time.Now())
+	})
 }
 
 func (r *chatRepository) CreateChat(ctx context.Context, chat *model.Chat) error {
@@ -74,6 +76,7 @@ func (r *chatRepository) AddUserToChat(ctx context.Context, chatID, userID uuid.
 		ChatID:   chatID,
 		UserID:   userID,
 		JoinedAt: time.Now(),
+		Role:     model.RoleMember,
 	}).Error
 }
 
@@ -82,3 +85,208 @@ func (r *chatRepository) RemoveUserFromChat(ctx context.Context, chatID, userID
 		Where("chat_id = ? AND user_id = ?", chatID, userID).
 		Delete(&model.ChatUser{}).Error
 }
+
+func (r *chatRepository) ListChatMembers(ctx context.Context, chatID uuid.UUID) ([]*model.ChatUser, error) {
+	var members []*model.ChatUser
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ?", chatID).
+		Order("joined_at ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// ListMembersPage returns a page of a chat's members in the order they joined
+func (r *chatRepository) ListMembersPage(ctx context.Context, chatID uuid.UUID, query model.MemberQuery) ([]*model.ChatUser, error) {
+	db := r.db.WithContext(ctx).Where("chat_id = ?", chatID)
+	if query.After != nil {
+		db = db.Where("(joined_at, user_id) > (?, ?)", query.After.JoinedAt, query.After.UserID)
+	}
+
+	var members []*model.ChatUser
+	err := db.Order("joined_at ASC, user_id ASC").
+		Limit(query.Limit).
+		Find(&members).Error
+	return members, err
+}
+
+func (r *chatRepository) MarkDelivered(ctx context.Context, chatID, userID uuid.UUID, seq int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.ChatUser{}).
+		Where("chat_id = ? AND user_id = ? AND last_delivered_seq < ?", chatID, userID, seq).
+		Update("last_delivered_seq", seq)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *chatRepository) MarkRead(ctx context.Context, chatID, userID, messageID uuid.UUID, seq int64) (bool, error) {
+	// Reading a message implies it was delivered
+	result := r.db.WithContext(ctx).Model(&model.ChatUser{}).
+		Where("chat_id = ? AND user_id = ? AND last_read_seq < ?", chatID, userID, seq).
+		Updates(map[string]interface{}{
+			"last_read_seq":        seq,
+			"last_read_message_id": messageID,
+			"last_delivered_seq":   gorm.Expr("GREATEST(last_delivered_seq, ?)", seq),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListMemberships returns the user's chat memberships with their chats loaded
+func (r *chatRepository) ListMemberships(ctx context.Context, userID uuid.UUID) ([]*model.ChatUser, error) {
+	var memberships []*model.ChatUser
+	err := r.db.WithContext(ctx).
+		Preload("Chat").
+		Where("user_id = ?", userID).
+		Find(&memberships).Error
+	return memberships, err
+}
+
+// CountUnreadMessages returns how many visible top-level messages from
+// others each of the given chats has past the user's read position. Chats
+// without unread messages are left out.
+func (r *chatRepository) CountUnreadMessages(ctx context.Context, userID uuid.UUID, chatIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		ChatID uuid.UUID
+		Unread int64
+	}
+	err := r.db.WithContext(ctx).
+		Table("chat_users").
+		Select("chat_users.chat_id, COUNT(*) AS unread").
+		Joins("JOIN messages ON messages.chat_id = chat_users.chat_id AND messages.seq > chat_users.last_read_seq").
+		Where("chat_users.user_id = ? AND chat_users.chat_id IN ?", userID, chatIDs).
+		Where("messages.parent_id IS NULL AND messages.deleted_at IS NULL AND messages.sender_id <> chat_users.user_id").
+		Group("chat_users.chat_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ChatID] = row.Unread
+	}
+	return counts, nil
+}
+
+// GetLastMessage returns the newest visible top-level message of a chat, or
+// nil if it has none. Thread replies and tombstones are not previewed.
+func (r *chatRepository) GetLastMessage(ctx context.Context, chatID uuid.UUID) (*model.Message, error) {
+	var message model.Message
+	err := r.db.WithContext(ctx).
+		Where("chat_id = ? AND parent_id IS NULL AND deleted_at IS NULL", chatID).
+		Order("seq DESC").
+		First(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &message, err
+}
+
+// AddChatMember adds a membership with the role it carries
+func (r *chatRepository) AddChatMember(ctx context.Context, member *model.ChatUser) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Create(member).Error
+}
+
+// GetChatMember returns a user's membership in a chat, or nil if they are
+// not a member
+func (r *chatRepository) GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error) {
+	var member model.ChatUser
+	err := r.db.WithContext(ctx).First(&member, "chat_id = ? AND user_id = ?", chatID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+// RenameChat changes the name of a chat
+func (r *chatRepository) RenameChat(ctx context.Context, chatID uuid.UUID, name string) error {
+	return r.db.WithContext(ctx).Model(&model.Chat{}).
+		Where("id = ?", chatID).
+		Update("name", name).Error
+}
+
+// SetChatVisibility changes who may join a chat without an invite
+func (r *chatRepository) SetChatVisibility(ctx context.Context, chatID uuid.UUID, visibility model.ChatVisibility) error {
+	return r.db.WithContext(ctx).Model(&model.Chat{}).
+		Where("id = ?", chatID).
+		Update("visibility", visibility).Error
+}
+
+// SetMemberRole changes the role of a member
+func (r *chatRepository) SetMemberRole(ctx context.Context, chatID, userID uuid.UUID, role model.ChatRole) error {
+	return r.db.WithContext(ctx).Model(&model.ChatUser{}).
+		Where("chat_id = ? AND user_id = ?", chatID, userID).
+		Update("role", role).Error
+}
+
+// TransferOwnership makes another member the owner of a chat and the
+// previous owner an admin
+func (r *chatRepository) TransferOwnership(ctx context.Context, chatID, fromID, toID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Demote first; a chat may only have one owner at a time
+		result := tx.Model(&model.ChatUser{}).
+			Where("chat_id = ? AND user_id = ? AND role = ?", chatID, fromID, model.RoleOwner).
+			Update("role", model.RoleAdmin)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMembershipChanged
+		}
+		result = tx.Model(&model.ChatUser{}).
+			Where("chat_id = ? AND user_id = ?", chatID, toID).
+			Update("role", model.RoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMembershipChanged
+		}
+		return nil
+	})
+}
+
+// FindOrCreateDirectChat creates a direct chat with the opener and the
+// others as members unless one with the same DM key exists, and reports
+// whether it was created. Opening an existing chat only adds the opener
+// back if they left it, which is reported as rejoined; others who left stay
+// out. Chats without a DM key are always created.
+func (r *chatRepository) FindOrCreateDirectChat(ctx context.Context, chat *model.Chat, opener *model.ChatUser, others []*model.ChatUser) (*model.Chat, bool, bool, error) {
+	created, rejoined := false, false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(chat)
+		if result.Error != nil {
+			return result.Error
+		}
+		created = result.RowsAffected > 0
+
+		members := []*model.ChatUser{opener}
+		if created {
+			members = append(members, others...)
+		} else {
+			// Another request made the chat first; use theirs
+			var existing model.Chat
+			if err := tx.First(&existing, "dm_key = ?", chat.DMKey).Error; err != nil {
+				return err
+			}
+			*chat = existing
+		}
+
+		for _, member := range members {
+			member.ChatID = chat.ID
+			result := tx.Omit(clause.Associations).
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(member)
+			if result.Error != nil {
+				return result.Error
+			}
+			if member == opener && !created {
+				rejoined = result.RowsAffected > 0
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, false, err
+	}
+	return chat, created, rejoined, nil
+}
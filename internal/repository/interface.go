@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"rtcs/internal/model"
 
@@ -13,7 +14,7 @@ type Repository interface {
 	// Message methods
 	CreateMessage(ctx context.Context, message *model.Message) error
 	GetMessage(ctx context.Context, id uuid.UUID) (*model.Message, error)
-	ListMessages(ctx context.Context, chatID uuid.UUID, limit, offset int) ([]*model.Message, error)
+	ListMessages(ctx context.Context, chatID uuid.UUID, query model.HistoryQuery) ([]*model.Message, error)
 	DeleteMessage(ctx context.Context, id uuid.UUID) error
 
 	// Chat methods
@@ -22,4 +23,38 @@ type Repository interface {
 	ListChats(ctx context.Context, userID uuid.UUID) ([]*model.Chat, error)
 	AddUserToChat(ctx context.Context, chatID, userID uuid.UUID) error
 	RemoveUserFromChat(ctx context.Context, chatID, userID uuid.UUID) error
+	ListChatMembers(ctx context.Context, chatID uuid.UUID) ([]*model.ChatUser, error)
+	ListMembersPage(ctx context.Context, chatID uuid.UUID, query model.MemberQuery) ([]*model.ChatUser, error)
+	ListMemberships(ctx context.Context, userID uuid.UUID) ([]*model.ChatUser, error)
+	CountUnreadMessages(ctx context.Context, userID uuid.UUID, chatIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+	GetLastMessage(ctx context.Context, chatID uuid.UUID) (*model.Message, error)
+
+	// Membership and role methods; GetChatMember returns nil for non-members
+	AddChatMember(ctx context.Context, member *model.ChatUser) error
+	GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error)
+	RenameChat(ctx context.Context, chatID uuid.UUID, name string) error
+	SetChatVisibility(ctx context.Context, chatID uuid.UUID, visibility model.ChatVisibility) error
+	SetMemberRole(ctx context.Context, chatID, userID uuid.UUID, role model.ChatRole) error
+	TransferOwnership(ctx context.Context, chatID, fromID, toID uuid.UUID) error
+	FindOrCreateDirectChat(ctx context.Context, chat *model.Chat, opener *model.ChatUser, others []*model.ChatUser) (found *model.Chat, created, rejoined bool, err error)
+
+	// Receipt methods; positions only move forward and the result reports
+	// whether it did
+	MarkDelivered(ctx context.Context, chatID, userID uuid.UUID, seq int64) (bool, error)
+	MarkRead(ctx context.Context, chatID, userID, messageID uuid.UUID, seq int64) (bool, error)
+
+	// Pin methods; PinMessage fails with ErrPinLimitReached once a chat has
+	// maxPins pins
+	PinMessage(ctx context.Context, pin *model.ChatPin, maxPins int) (bool, error)
+	UnpinMessage(ctx context.Context, chatID, messageID uuid.UUID) (bool, error)
+	ListPins(ctx context.Context, chatID uuid.UUID) ([]*model.ChatPin, error)
+
+	// Invite methods; GetInvite returns nil for unknown invites and
+	// AcceptInvite fails with ErrInviteNotFound, ErrInviteExpired or
+	// ErrInviteUsedUp for invites that cannot be accepted
+	CreateInvite(ctx context.Context, invite *model.ChatInvite) error
+	GetInvite(ctx context.Context, inviteID uuid.UUID) (*model.ChatInvite, error)
+	ListInvites(ctx context.Context, chatID uuid.UUID) ([]*model.ChatInvite, error)
+	RevokeInvite(ctx context.Context, inviteID, revokedBy uuid.UUID, revokedAt time.Time) error
+	AcceptInvite(ctx context.Context, tokenHash string, userID uuid.UUID, now time.Time) (*model.ChatInvite, bool, error)
 }
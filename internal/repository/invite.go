@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInviteNotFound is returned for invite tokens that were never issued
+	// or have been revoked
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrInviteExpired is returned for invites past their expiry
+	ErrInviteExpired = errors.New("invite expired")
+	// ErrInviteUsedUp is returned for invites accepted as often as allowed
+	ErrInviteUsedUp = errors.New("invite used up")
+)
+
+// CreateInvite stores a new invite
+func (r *chatRepository) CreateInvite(ctx context.Context, invite *model.ChatInvite) error {
+	return r.db.WithContext(ctx).Omit("Accepted").Create(invite).Error
+}
+
+// GetInvite returns an invite, or nil if it does not exist
+func (r *chatRepository) GetInvite(ctx context.Context, inviteID uuid.UUID) (*model.ChatInvite, error) {
+	var invite model.ChatInvite
+	err := r.db.WithContext(ctx).First(&invite, "id = ?", inviteID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invite, err
+}
+
+// ListInvites returns a chat's invites with the users who accepted them,
+// newest first
+func (r *chatRepository) ListInvites(ctx context.Context, chatID uuid.UUID) ([]*model.ChatInvite, error) {
+	var invites []*model.ChatInvite
+	err := r.db.WithContext(ctx).
+		Preload("Accepted", func(db *gorm.DB) *gorm.DB {
+			return db.Order("used_at ASC")
+		}).
+		Where("chat_id = ?", chatID).
+		Order("created_at DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+// RevokeInvite stops an invite from being accepted
+func (r *chatRepository) RevokeInvite(ctx context.Context, inviteID, revokedBy uuid.UUID, revokedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.ChatInvite{}).
+		Where("id = ? AND revoked_at IS NULL", inviteID).
+		Updates(map[string]interface{}{
+			"revoked_at": revokedAt,
+			"revoked_by": revokedBy,
+		}).Error
+}
+
+// AcceptInvite adds the user to the invite's chat and counts the use. It
+// reports whether the user joined; accepting an invite to a chat the user
+// already belongs to leaves the invite unused.
+func (r *chatRepository) AcceptInvite(ctx context.Context, tokenHash string, userID uuid.UUID, now time.Time) (*model.ChatInvite, bool, error) {
+	var invite model.ChatInvite
+	joined := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the invite serializes concurrent accepts, so uses never
+		// exceed the limit
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&invite, "token_hash = ? AND revoked_at IS NULL", tokenHash).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInviteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !now.Before(invite.ExpiresAt) {
+			return ErrInviteExpired
+		}
+
+		var existing int64
+		if err := tx.Model(&model.ChatUser{}).
+			Where("chat_id = ? AND user_id = ?", invite.ChatID, userID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+		if invite.Uses >= invite.MaxUses {
+			return ErrInviteUsedUp
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&model.ChatUser{
+			ChatID:    invite.ChatID,
+			UserID:    userID,
+			JoinedAt:  now,
+			Role:      model.RoleMember,
+			InvitedBy: &invite.CreatedBy,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.ChatInviteUse{InviteID: invite.ID, UserID: userID, UsedAt: now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&invite).Update("uses", gorm.Expr("uses + 1")).Error; err != nil {
+			return err
+		}
+		invite.Uses++
+		joined = true
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &invite, joined, nil
+}
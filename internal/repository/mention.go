@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetChatMemberIDs returns the IDs of a chat's members
+func (r *MessageRepository) GetChatMemberIDs(ctx context.Context, chatID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&model.ChatUser{}).
+		Where("chat_id = ?", chatID).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// mentionRow is a message read with its unread state
+type mentionRow struct {
+	model.Message
+	Unread bool
+}
+
+// GetMentions returns a page of the messages that mention the user by name
+// or through @channel, newest first. Only chats the user still belongs to
+// are included, and a mention is unread while the message is after the
+// user's read position in its chat.
+func (r *MessageRepository) GetMentions(ctx context.Context, userID uuid.UUID, query model.MentionQuery) ([]*model.InboxMention, error) {
+	db := mentionsOf(r.db.WithContext(ctx), userID)
+	if query.UnreadOnly {
+		db = db.Where("messages.seq > chat_users.last_read_seq")
+	}
+	if query.Before != nil {
+		db = db.Where("(messages.created_at, messages.id) < (?, ?)", query.Before.CreatedAt, query.Before.ID)
+	}
+
+	var rows []mentionRow
+	err := db.Select("messages.*, messages.seq > chat_users.last_read_seq AS unread").
+		Order("messages.created_at DESC, messages.id DESC").
+		Limit(query.Limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mentions := make([]*model.InboxMention, 0, len(rows))
+	messageIDs := make([]uuid.UUID, 0, len(rows))
+	for i := range rows {
+		mentions = append(mentions, &model.InboxMention{Message: &rows[i].Message, Unread: rows[i].Unread})
+		messageIDs = append(messageIDs, rows[i].ID)
+	}
+	if err := r.loadMentions(ctx, mentions, messageIDs); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+// CountUnreadMentions returns how many mentions of the user are unread
+func (r *MessageRepository) CountUnreadMentions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := mentionsOf(r.db.WithContext(ctx), userID).
+		Where("messages.seq > chat_users.last_read_seq").
+		Count(&count).Error
+	return count, err
+}
+
+// mentionsOf selects the live messages mentioning the user in their chats
+func mentionsOf(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Table("messages").
+		Joins("JOIN chat_users ON chat_users.chat_id = messages.chat_id AND chat_users.user_id = ?", userID).
+		Where("messages.deleted_at IS NULL AND messages.sender_id <> ?", userID).
+		Where("messages.mentions_channel OR EXISTS (SELECT 1 FROM message_mentions WHERE message_mentions.message_id = messages.id AND message_mentions.user_id = ?)", userID)
+}
+
+// loadMentions fills in the named mentions of inbox messages
+func (r *MessageRepository) loadMentions(ctx context.Context, mentions []*model.InboxMention, messageIDs []uuid.UUID) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	var rows []model.MessageMention
+	if err := r.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Find(&rows).Error; err != nil {
+		return err
+	}
+	byMessage := make(map[uuid.UUID][]model.MessageMention)
+	for _, row := range rows {
+		byMessage[row.MessageID] = append(byMessage[row.MessageID], row)
+	}
+	for _, mention := range mentions {
+		mention.Message.Mentions = byMessage[mention.Message.ID]
+	}
+	return nil
+}
@@ -2,12 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"rtcs/internal/model"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MessageRepository handles database operations for messages
@@ -22,50 +24,275 @@ func NewMessageRepository(db *gorm.DB) *MessageRepository {
 	}
 }
 
-// SaveMessage saves a message to the database
+// SaveMessage saves a message to the database, assigning the next sequence
+// number of its chat
 func (r *MessageRepository) SaveMessage(ctx context.Context, message *model.Message) error {
-	return r.db.WithContext(ctx).Create(message).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createSequencedMessage(tx, message)
+	})
 }
 
-// GetMessages retrieves messages for a chat
-func (r *MessageRepository) GetMessages(ctx context.Context, chatID uuid.UUID, limit int) ([]*model.Message, error) {
+// createSequencedMessage inserts a message with the next sequence number of
+// its chat; tx must be a transaction
+func createSequencedMessage(tx *gorm.DB, message *model.Message) error {
+	// The row lock taken by the update serializes senders within a chat
+	var seq int64
+	err := tx.Raw("UPDATE chats SET last_seq = last_seq + 1 WHERE id = ? RETURNING last_seq", message.ChatID).
+		Scan(&seq).Error
+	if err != nil {
+		return err
+	}
+
+	message.Seq = seq
+	if err := tx.Omit(clause.Associations).Create(message).Error; err != nil {
+		return err
+	}
+	if err := claimAttachments(tx, message); err != nil {
+		return err
+	}
+	if len(message.Mentions) > 0 {
+		if err := tx.Create(&message.Mentions).Error; err != nil {
+			return err
+		}
+	}
+
+	if message.ParentID != nil {
+		err := tx.Model(&model.Message{}).
+			Where("id = ?", *message.ParentID).
+			Updates(map[string]interface{}{
+				"reply_count":   gorm.Expr("reply_count + 1"),
+				"last_reply_at": message.CreatedAt,
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// Senders have read their own message
+	return tx.Model(&model.ChatUser{}).
+		Where("chat_id = ? AND user_id = ? AND last_read_seq < ?", message.ChatID, message.SenderID, seq).
+		Updates(map[string]interface{}{
+			"last_read_seq":        seq,
+			"last_read_message_id": message.ID,
+			"last_delivered_seq":   gorm.Expr("GREATEST(last_delivered_seq, ?)", seq),
+		}).Error
+}
+
+// GetMessagesAfter retrieves messages of a chat with a sequence greater than
+// afterSeq, oldest first
+func (r *MessageRepository) GetMessagesAfter(ctx context.Context, chatID uuid.UUID, afterSeq int64, limit int) ([]*model.Message, error) {
 	var messages []*model.Message
 	err := r.db.WithContext(ctx).
-		Where("chat_id = ?", chatID).
-		Order("created_at DESC").
+		Preload("Attachments").
+		Preload("Mentions").
+		Where("chat_id = ? AND seq > ?", chatID, afterSeq).
+		Order("seq ASC").
 		Limit(limit).
 		Find(&messages).Error
 	return messages, err
 }
 
-// GetMessage retrieves a message by ID
+// GetMessages retrieves the newest top-level messages of a chat; thread
+// replies are read with GetReplies
+func (r *MessageRepository) GetMessages(ctx context.Context, chatID uuid.UUID, limit int) ([]*model.Message, error) {
+	return r.GetMessagePage(ctx, chatID, model.HistoryQuery{Limit: limit})
+}
+
+// GetMessagePage retrieves a page of the top-level messages of a chat,
+// newest first
+func (r *MessageRepository) GetMessagePage(ctx context.Context, chatID uuid.UUID, query model.HistoryQuery) ([]*model.Message, error) {
+	return messagePage(r.db.WithContext(ctx), chatID, query)
+}
+
+// messagePage seeks to the query's cursor on (created_at, id), which the
+// idx_messages_chat_history index serves without scanning skipped rows
+func messagePage(db *gorm.DB, chatID uuid.UUID, query model.HistoryQuery) ([]*model.Message, error) {
+	db = db.Preload("Attachments").Preload("Mentions").Where("chat_id = ? AND parent_id IS NULL", chatID)
+	switch {
+	case query.After != nil:
+		db = db.Where("(created_at, id) > (?, ?)", query.After.CreatedAt, query.After.ID).
+			Order("created_at ASC, id ASC")
+	case query.Before != nil:
+		db = db.Where("(created_at, id) < (?, ?)", query.Before.CreatedAt, query.Before.ID).
+			Order("created_at DESC, id DESC")
+	default:
+		db = db.Order("created_at DESC, id DESC")
+	}
+
+	var messages []*model.Message
+	if err := db.Limit(query.Limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	// Pages after a cursor are read oldest first to take the messages next
+	// to it, then flipped to the usual order
+	if query.After != nil {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, nil
+}
+
+// GetReplies retrieves the replies to a thread root with a sequence greater
+// than afterSeq, oldest first
+func (r *MessageRepository) GetReplies(ctx context.Context, parentID uuid.UUID, afterSeq int64, limit int) ([]*model.Message, error) {
+	var replies []*model.Message
+	err := r.db.WithContext(ctx).
+		Preload("Attachments").
+		Preload("Mentions").
+		Where("parent_id = ? AND seq > ?", parentID, afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&replies).Error
+	return replies, err
+}
+
+// GetThreadParticipants returns the author of a thread root and everyone who
+// replied to it
+func (r *MessageRepository) GetThreadParticipants(ctx context.Context, parentID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Model(&model.Message{}).
+		Where("id = ? OR parent_id = ?", parentID, parentID).
+		Distinct().
+		Pluck("sender_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetMessage retrieves a message by ID, or nil if it does not exist
 func (r *MessageRepository) GetMessage(ctx context.Context, messageID uuid.UUID) (*model.Message, error) {
 	var message model.Message
-	err := r.db.WithContext(ctx).First(&message, "id = ?", messageID).Error
+	err := r.db.WithContext(ctx).Preload("Attachments").Preload("Mentions").First(&message, "id = ?", messageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &message, nil
 }
 
-// DeleteMessage deletes a message
+// UpdateMessageText replaces the text of a message, keeping the previous
+// text as a revision
+func (r *MessageRepository) UpdateMessageText(ctx context.Context, messageID, editorID uuid.UUID, text string, editedAt time.Time) (*model.Message, error) {
+	var message model.Message
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the row so concurrent edits each record the text they replace
+		if err := tx.Preload("Attachments").Preload("Mentions").Clauses(clause.Locking{Strength: "UPDATE"}).First(&message, "id = ?", messageID).Error; err != nil {
+			return err
+		}
+
+		revision := &model.MessageRevision{
+			MessageID: message.ID,
+			Text:      message.Text,
+			EditedBy:  editorID,
+			CreatedAt: editedAt,
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+
+		message.Text = text
+		message.EditedAt = &editedAt
+		return tx.Model(&message).Updates(map[string]interface{}{
+			"text":       text,
+			"edited_at":  editedAt,
+			"updated_at": editedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	message.UpdatedAt = editedAt
+	return &message, nil
+}
+
+// DeleteMessage replaces a message with a tombstone
 func (r *MessageRepository) DeleteMessage(ctx context.Context, messageID uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Message{}, "id = ?", messageID).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tombstoneMessage(tx, messageID, nil, "", time.Now())
+	})
+}
+
+// TombstoneMessage replaces a message with a tombstone recording who deleted
+// it and why, and returns the tombstone
+func (r *MessageRepository) TombstoneMessage(ctx context.Context, messageID, deletedBy uuid.UUID, reason string, deletedAt time.Time) (*model.Message, error) {
+	var message model.Message
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tombstoneMessage(tx, messageID, &deletedBy, reason, deletedAt); err != nil {
+			return err
+		}
+		return tx.First(&message, "id = ?", messageID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
 }
 
-// CreateChatIfNotExists creates a new chat if it doesn't exist
-func (r *MessageRepository) CreateChatIfNotExists(ctx context.Context, chat *model.Chat) error {
-	result := r.db.WithContext(ctx).FirstOrCreate(chat, model.Chat{ID: chat.ID})
-	return result.Error
+// tombstoneMessage clears the content of a message but keeps its row, so
+// history and sequence numbers keep their shape; tx must be a transaction
+func tombstoneMessage(tx *gorm.DB, messageID uuid.UUID, deletedBy *uuid.UUID, reason string, deletedAt time.Time) error {
+	err := tx.Model(&model.Message{}).
+		Where("id = ? AND deleted_at IS NULL", messageID).
+		Updates(map[string]interface{}{
+			"text":          model.DeletedMessageText,
+			"deleted_at":    deletedAt,
+			"deleted_by":    deletedBy,
+			"delete_reason": reason,
+			"updated_at":    deletedAt,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	// Revisions would otherwise keep the deleted text around
+	if err := tx.Where("message_id = ?", messageID).Delete(&model.MessageRevision{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id = ?", messageID).Delete(&model.MessageReaction{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id = ?", messageID).Delete(&model.ChatPin{}).Error; err != nil {
+		return err
+	}
+	// Deleted messages leave the mention inboxes
+	if err := tx.Where("message_id = ?", messageID).Delete(&model.MessageMention{}).Error; err != nil {
+		return err
+	}
+	// The caller removes the files themselves from the blob store
+	return tx.Where("message_id = ?", messageID).Delete(&model.Attachment{}).Error
+}
+
+// PurgeDeletedMessages permanently removes tombstones of messages deleted
+// before the given time and returns how many were removed. Thread roots stay
+// as long as they have replies, which refer to them; a later purge removes
+// them once their replies are gone.
+func (r *MessageRepository) PurgeDeletedMessages(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM messages replies WHERE replies.parent_id = messages.id)").
+		Delete(&model.Message{})
+	return result.RowsAffected, result.Error
+}
+
+// GetChat returns a chat, or nil if it does not exist
+func (r *MessageRepository) GetChat(ctx context.Context, chatID uuid.UUID) (*model.Chat, error) {
+	var chat model.Chat
+	err := r.db.WithContext(ctx).First(&chat, "id = ?", chatID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &chat, err
 }
 
-// AddUserToChat adds a user to a chat if they're not already a member
-func (r *MessageRepository) AddUserToChat(ctx context.Context, chatID, userID uuid.UUID) error {
-	chatUser := &model.ChatUser{
-		ChatID:   chatID,
-		UserID:   userID,
-		JoinedAt: time.Now(),
+// GetChatMember returns a user's membership in a chat, or nil if they are
+// not a member
+func (r *MessageRepository) GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error) {
+	var member model.ChatUser
+	err := r.db.WithContext(ctx).First(&member, "chat_id = ? AND user_id = ?", chatID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
 	}
-	result := r.db.WithContext(ctx).FirstOrCreate(chatUser, model.ChatUser{ChatID: chatID, UserID: userID})
-	return result.Error
+	return &member, err
 }
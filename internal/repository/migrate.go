@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"fmt"
+
+	"rtcs/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Migrate brings the schema up to date with the models, including the steps
+// AutoMigrate cannot express, and backfills data older rows are missing
+func Migrate(db *gorm.DB) error {
+	// Messages stored before sequence numbers existed all have seq 0, which
+	// the unique (chat_id, seq) index AutoMigrate creates would reject; see
+	// migrations/003_message_sequence.sql
+	if db.Migrator().HasTable(&model.Message{}) {
+		for _, statement := range []string{
+			"ALTER TABLE chats ADD COLUMN IF NOT EXISTS last_seq BIGINT NOT NULL DEFAULT 0",
+			"ALTER TABLE messages ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0",
+			`UPDATE messages SET seq = numbered.seq FROM (
+				SELECT messages.id, COALESCE(numbered_chats.max_seq, 0) + ROW_NUMBER() OVER (PARTITION BY messages.chat_id ORDER BY messages.created_at, messages.id) AS seq
+				FROM messages LEFT JOIN (SELECT chat_id, MAX(seq) AS max_seq FROM messages GROUP BY chat_id) AS numbered_chats ON numbered_chats.chat_id = messages.chat_id
+				WHERE messages.seq = 0
+			) AS numbered
+			WHERE messages.id = numbered.id`,
+			"UPDATE chats SET last_seq = GREATEST(last_seq, COALESCE((SELECT MAX(seq) FROM messages WHERE messages.chat_id = chats.id), 0))",
+		} {
+			if err := db.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to number existing messages: %w", err)
+			}
+		}
+	}
+
+	if err := db.AutoMigrate(
+		&model.User{},
+		&model.Chat{},
+		&model.ChatUser{},
+		&model.Message{},
+		&model.MessageRevision{},
+		&model.MessageReaction{},
+		&model.Attachment{},
+		&model.ChatPin{},
+		&model.MessageMention{},
+		&model.ChatInvite{},
+		&model.ChatInviteUse{},
+	); err != nil {
+		return err
+	}
+	// The search vector is generated by the database, which AutoMigrate
+	// cannot declare; see migrations/010_message_search.sql
+	for _, statement := range []string{
+		"ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED",
+		"CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to set up message search: %w", err)
+		}
+	}
+	// Chats created before roles existed are owned by their earliest member;
+	// see migrations/015_chat_roles.sql
+	for _, statement := range []string{
+		`UPDATE chat_users SET role = 'owner' FROM (SELECT DISTINCT ON (chat_id) chat_id, user_id FROM chat_users ORDER BY chat_id, joined_at, user_id) AS first_member
+		WHERE chat_users.chat_id = first_member.chat_id AND chat_users.user_id = first_member.user_id
+		AND NOT EXISTS (SELECT 1 FROM chat_users AS owner WHERE owner.chat_id = chat_users.chat_id AND owner.role = 'owner')`,
+		"UPDATE chats SET created_by = chat_users.user_id FROM chat_users WHERE chat_users.chat_id = chats.id AND chat_users.role = 'owner' AND chats.created_by IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_users_owner ON chat_users(chat_id) WHERE role = 'owner'",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to set up chat roles: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPinLimitReached is returned when a chat already has the most pins allowed
+var ErrPinLimitReached = errors.New("pin limit reached")
+
+// PinMessage pins a message unless the chat already has maxPins pins, and
+// reports whether it was newly pinned
+func (r *chatRepository) PinMessage(ctx context.Context, pin *model.ChatPin, maxPins int) (bool, error) {
+	pinned := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the chat serializes concurrent pins, so the count holds
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&model.Chat{}, "id = ?", pin.ChatID).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&model.ChatPin{}).
+			Where("chat_id = ? AND message_id = ?", pin.ChatID, pin.MessageID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		var count int64
+		if err := tx.Model(&model.ChatPin{}).Where("chat_id = ?", pin.ChatID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(maxPins) {
+			return ErrPinLimitReached
+		}
+
+		if err := tx.Omit(clause.Associations).Create(pin).Error; err != nil {
+			return err
+		}
+		pinned = true
+		return nil
+	})
+	return pinned, err
+}
+
+// UnpinMessage removes a pin and reports whether it existed
+func (r *chatRepository) UnpinMessage(ctx context.Context, chatID, messageID uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Delete(&model.ChatPin{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListPins returns a chat's pins with their messages, most recent first
+func (r *chatRepository) ListPins(ctx context.Context, chatID uuid.UUID) ([]*model.ChatPin, error) {
+	var pins []*model.ChatPin
+	err := r.db.WithContext(ctx).
+		Preload("Message").
+		Preload("Message.Attachments").
+		Where("chat_id = ?", chatID).
+		Order("pinned_at DESC").
+		Find(&pins).Error
+	return pins, err
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// AddReaction stores a reaction and reports whether it was new
+func (r *MessageRepository) AddReaction(ctx context.Context, reaction *model.MessageReaction) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(reaction)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RemoveReaction deletes a reaction and reports whether it existed
+func (r *MessageRepository) RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&model.MessageReaction{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetReactionSummaries aggregates the reactions to the given messages by
+// emoji, flagging the ones viewerID reacted with
+func (r *MessageRepository) GetReactionSummaries(ctx context.Context, messageIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID][]model.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID][]model.ReactionSummary)
+	if len(messageIDs) == 0 {
+		return summaries, nil
+	}
+
+	var rows []struct {
+		MessageID   uuid.UUID
+		Emoji       string
+		Count       int64
+		ReactedByMe bool
+	}
+	err := r.db.WithContext(ctx).Model(&model.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted_by_me, MIN(created_at) AS first_at", viewerID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("first_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		summaries[row.MessageID] = append(summaries[row.MessageID], model.ReactionSummary{
+			Emoji:       row.Emoji,
+			Count:       row.Count,
+			ReactedByMe: row.ReactedByMe,
+		})
+	}
+	return summaries, nil
+}
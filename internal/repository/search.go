@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// searchConfig is the text search configuration of messages.search_vector.
+// Messages are in many languages, so words are matched without stemming.
+const searchConfig = "simple"
+
+// Snippets are highlighted with control characters, which survive HTML
+// escaping, and the markers are swapped for tags afterwards
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+type searchRow struct {
+	model.Message
+	Snippet string
+}
+
+// SearchMessages finds messages matching the query in the chats the user
+// belongs to, newest first. Deleted messages are never returned.
+func (r *MessageRepository) SearchMessages(ctx context.Context, userID uuid.UUID, query model.SearchQuery) ([]*model.SearchResult, error) {
+	tsquery := "websearch_to_tsquery('" + searchConfig + "', ?)"
+	db := r.db.WithContext(ctx).
+		Table("messages").
+		Select("messages.*, ts_headline('"+searchConfig+"', messages.text, "+tsquery+", ?) AS snippet",
+			query.Text, "StartSel="+highlightStart+", StopSel="+highlightStop+", MaxWords=30, MinWords=10").
+		Joins("JOIN chat_users ON chat_users.chat_id = messages.chat_id AND chat_users.user_id = ?", userID).
+		Where("messages.search_vector @@ "+tsquery, query.Text).
+		Where("messages.deleted_at IS NULL")
+
+	if query.ChatID != nil {
+		db = db.Where("messages.chat_id = ?", *query.ChatID)
+	}
+	if query.SenderID != nil {
+		db = db.Where("messages.sender_id = ?", *query.SenderID)
+	}
+	if query.From != nil {
+		db = db.Where("messages.created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("messages.created_at < ?", *query.To)
+	}
+	if query.Before != nil {
+		db = db.Where("(messages.created_at, messages.id) < (?, ?)", query.Before.CreatedAt, query.Before.ID)
+	}
+
+	var rows []searchRow
+	err := db.Order("messages.created_at DESC, messages.id DESC").
+		Limit(query.Limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*model.SearchResult, 0, len(rows))
+	for i := range rows {
+		results = append(results, &model.SearchResult{
+			Message: &rows[i].Message,
+			Snippet: highlight(rows[i].Snippet),
+		})
+	}
+	return results, nil
+}
+
+// highlight escapes a snippet for HTML and marks the matched words
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
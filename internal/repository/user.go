@@ -55,6 +55,13 @@ func (r *UserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, profil
 		}).Error
 }
 
+// UpdateAvatarURL replaces only a user's avatar URL
+func (r *UserRepository) UpdateAvatarURL(ctx context.Context, id uuid.UUID, avatarURL string) error {
+	return r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Update("avatar_url", avatarURL).Error
+}
+
 // GetProfiles retrieves profiles for multiple users
 func (r *UserRepository) GetProfiles(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*model.UserProfile, error) {
 	var users []model.User
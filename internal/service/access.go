@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// MemberLookup finds a user's membership of a chat, nil if they have none
+type MemberLookup interface {
+	GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error)
+}
+
+// MembershipCache remembers users' roles in chats. A nil role is a miss; an
+// empty role records that the user is not a member.
+type MembershipCache interface {
+	GetChatRole(ctx context.Context, chatID, userID string) (*model.ChatRole, error)
+	SetChatRole(ctx context.Context, chatID, userID string, role model.ChatRole) error
+	DeleteChatRole(ctx context.Context, chatID, userID string) error
+}
+
+// ChatAccess decides whether users may read and write chats. Lookups are
+// cached when a cache is set, so every change of membership or role must be
+// followed by Forget.
+type ChatAccess struct {
+	members MemberLookup
+	cache   MembershipCache
+}
+
+// NewChatAccess creates an access check over the given memberships; cache
+// may be nil
+func NewChatAccess(members MemberLookup, cache MembershipCache) *ChatAccess {
+	return &ChatAccess{members: members, cache: cache}
+}
+
+// Role returns the user's role in the chat, or ErrNotChatMember
+func (a *ChatAccess) Role(ctx context.Context, chatID, userID uuid.UUID) (model.ChatRole, error) {
+	if a.cache != nil {
+		if role, err := a.cache.GetChatRole(ctx, chatID.String(), userID.String()); err == nil && role != nil {
+			if *role == "" {
+				return "", ErrNotChatMember
+			}
+			return *role, nil
+		}
+	}
+
+	member, err := a.members.GetChatMember(ctx, chatID, userID)
+	if err != nil {
+		return "", err
+	}
+	var role model.ChatRole
+	if member != nil {
+		role = member.Role
+	}
+
+	if a.cache != nil {
+		if err := a.cache.SetChatRole(ctx, chatID.String(), userID.String(), role); err != nil {
+			log.Printf("[WARN] Failed to cache role of user %s in chat %s: %v", userID, chatID, err)
+		}
+	}
+
+	if role == "" {
+		return "", ErrNotChatMember
+	}
+	return role, nil
+}
+
+// RequireMember returns ErrNotChatMember unless the user belongs to the chat
+func (a *ChatAccess) RequireMember(ctx context.Context, chatID, userID uuid.UUID) error {
+	_, err := a.Role(ctx, chatID, userID)
+	return err
+}
+
+// Require returns ErrNotChatMember or ErrPermissionDenied unless the user's
+// role in the chat permits the action
+func (a *ChatAccess) Require(ctx context.Context, chatID, userID uuid.UUID, action ChatAction) error {
+	role, err := a.Role(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !Can(role, action) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// Forget drops what is cached about the user's membership of the chat
+func (a *ChatAccess) Forget(ctx context.Context, chatID, userID uuid.UUID) {
+	if a.cache == nil {
+		return
+	}
+	if err := a.cache.DeleteChatRole(ctx, chatID.String(), userID.String()); err != nil {
+		log.Printf("[WARN] Failed to invalidate role of user %s in chat %s: %v", userID, chatID, err)
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rtcs/internal/imaging"
+	"rtcs/internal/model"
+	"rtcs/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxFileNameRunes bounds stored file names to their column
+	maxFileNameRunes = 255
+	// attachmentThumbnailSize bounds the preview of image attachments
+	attachmentThumbnailSize = 320
+)
+
+// AttachmentRepository stores attachment metadata
+type AttachmentRepository interface {
+	CreateAttachment(ctx context.Context, attachment *model.Attachment) error
+	GetAttachment(ctx context.Context, attachmentID uuid.UUID) (*model.Attachment, error)
+}
+
+// AttachmentService stores files uploaded to chats and serves them to the
+// chats' members
+type AttachmentService struct {
+	repo           AttachmentRepository
+	blobs          storage.BlobStore
+	chats          *ChatService
+	defaultMaxSize int64
+}
+
+// NewAttachmentService creates an attachment service. defaultMaxSize is the
+// upload limit of chats without their own.
+func NewAttachmentService(repo AttachmentRepository, blobs storage.BlobStore, chats *ChatService, defaultMaxSize int64) *AttachmentService {
+	return &AttachmentService{
+		repo:           repo,
+		blobs:          blobs,
+		chats:          chats,
+		defaultMaxSize: defaultMaxSize,
+	}
+}
+
+// UploadLimit returns the largest file the user may upload to the chat
+func (s *AttachmentService) UploadLimit(ctx context.Context, chatID, userID uuid.UUID) (int64, error) {
+	if err := s.chats.RequireMember(ctx, chatID, userID); err != nil {
+		return 0, err
+	}
+
+	chat, err := s.chats.GetChat(ctx, chatID)
+	if err != nil {
+		return 0, err
+	}
+	if chat != nil && chat.MaxAttachmentSize > 0 {
+		return chat.MaxAttachmentSize, nil
+	}
+	return s.defaultMaxSize, nil
+}
+
+// Upload stores a file for the user to send to the chat. The content type
+// is sniffed from the content rather than trusted from the client.
+func (s *AttachmentService) Upload(ctx context.Context, chatID, userID uuid.UUID, fileName string, content io.ReadSeeker, size int64) (*model.Attachment, error) {
+	limit, err := s.UploadLimit(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidAttachment)
+	}
+	if size > limit {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrAttachmentTooLarge, size, limit)
+	}
+
+	contentType, err := sniffContentType(content)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &model.Attachment{
+		ID:          uuid.New(),
+		ChatID:      chatID,
+		UploaderID:  userID,
+		FileName:    cleanFileName(fileName),
+		ContentType: contentType,
+		Size:        size,
+		CreatedAt:   time.Now(),
+	}
+	attachment.StorageKey = "attachments/" + chatID.String() + "/" + attachment.ID.String()
+
+	if err := s.blobs.Put(ctx, attachment.StorageKey, content, size, contentType); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if isThumbnailable(contentType) {
+		s.storeThumbnail(ctx, attachment, content)
+	}
+	if err := s.repo.CreateAttachment(ctx, attachment); err != nil {
+		deleteAttachmentFiles(ctx, s.blobs, attachment)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// storeThumbnail stores a preview of an image attachment. An image that
+// cannot be decoded is still a valid file, so it is only sent without one.
+func (s *AttachmentService) storeThumbnail(ctx context.Context, attachment *model.Attachment, content io.ReadSeeker) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		log.Printf("[WARN] Failed to rewind attachment %s: %v", attachment.ID, err)
+		return
+	}
+	img, err := imaging.Decode(content)
+	if err != nil {
+		log.Printf("[INFO] No thumbnail for attachment %s: %v", attachment.ID, err)
+		return
+	}
+
+	key := attachment.StorageKey + "-thumbnail"
+	if err := putJPEG(ctx, s.blobs, key, imaging.Fit(img, attachmentThumbnailSize)); err != nil {
+		log.Printf("[WARN] Failed to store thumbnail of attachment %s: %v", attachment.ID, err)
+		return
+	}
+	attachment.ThumbnailKey = key
+	attachment.Width = img.Bounds().Dx()
+	attachment.Height = img.Bounds().Dy()
+}
+
+// Open returns an attachment and its content if the user belongs to its
+// chat; the caller closes the content
+func (s *AttachmentService) Open(ctx context.Context, attachmentID, userID uuid.UUID) (*model.Attachment, io.ReadCloser, error) {
+	attachment, err := s.visibleAttachment(ctx, attachmentID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.openBlob(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// OpenThumbnail returns an image attachment and its JPEG preview on the
+// same terms as Open
+func (s *AttachmentService) OpenThumbnail(ctx context.Context, attachmentID, userID uuid.UUID) (*model.Attachment, io.ReadCloser, error) {
+	attachment, err := s.visibleAttachment(ctx, attachmentID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment.ThumbnailKey == "" {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	content, err := s.openBlob(ctx, attachment.ThumbnailKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// visibleAttachment loads an attachment the user may download
+func (s *AttachmentService) visibleAttachment(ctx context.Context, attachmentID, userID uuid.UUID) (*model.Attachment, error) {
+	attachment, err := s.repo.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment == nil {
+		return nil, ErrAttachmentNotFound
+	}
+
+	if err := s.chats.RequireMember(ctx, attachment.ChatID, userID); err != nil {
+		return nil, err
+	}
+	// Until it is sent, only the uploader can see an attachment
+	if attachment.MessageID == nil && attachment.UploaderID != userID {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+func (s *AttachmentService) openBlob(ctx context.Context, key string) (io.ReadCloser, error) {
+	content, err := s.blobs.Get(ctx, key)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	return content, err
+}
+
+// sniffContentType detects the type from the first bytes and rewinds
+func sniffContentType(content io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// cleanFileName keeps the base name of a client supplied file name
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimSpace(strings.ToValidUTF8(name, ""))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	if utf8.RuneCountInString(name) > maxFileNameRunes {
+		name = string([]rune(name)[:maxFileNameRunes])
+	}
+	return name
+}
+
+// maxAttachmentsPerMessage bounds how many files one message carries
+const maxAttachmentsPerMessage = 10
+
+// sendableAttachments loads the attachments a sender wants to send with a
+// message. Each must have been uploaded to the chat by the sender and not be
+// sent yet.
+func (s *MessageService) sendableAttachments(ctx context.Context, chatID, senderID uuid.UUID, attachmentIDStrs []string) ([]model.Attachment, error) {
+	if len(attachmentIDStrs) == 0 {
+		return nil, nil
+	}
+	if len(attachmentIDStrs) > maxAttachmentsPerMessage {
+		return nil, fmt.Errorf("%w: at most %d attachments per message", ErrInvalidAttachment, maxAttachmentsPerMessage)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	attachmentIDs := make([]uuid.UUID, 0, len(attachmentIDStrs))
+	for _, idStr := range attachmentIDStrs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, idStr)
+		}
+		if !seen[id] {
+			seen[id] = true
+			attachmentIDs = append(attachmentIDs, id)
+		}
+	}
+
+	found, err := s.repo.GetAttachments(ctx, attachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) != len(attachmentIDs) {
+		return nil, ErrAttachmentNotFound
+	}
+
+	attachments := make([]model.Attachment, 0, len(found))
+	for _, attachment := range found {
+		if attachment.ChatID != chatID || attachment.UploaderID != senderID || attachment.MessageID != nil {
+			return nil, ErrAttachmentNotFound
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, nil
+}
+
+// deleteAttachmentBlobs removes the files of a deleted message. Failures
+// only leave unreachable files behind, so they are logged.
+func (s *MessageService) deleteAttachmentBlobs(ctx context.Context, attachments []model.Attachment) {
+	if s.blobs == nil {
+		return
+	}
+	for i := range attachments {
+		deleteAttachmentFiles(ctx, s.blobs, &attachments[i])
+	}
+}
+
+// deleteAttachmentFiles removes an attachment's file and preview
+func deleteAttachmentFiles(ctx context.Context, blobs storage.BlobStore, attachment *model.Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := blobs.Delete(ctx, key); err != nil {
+			log.Printf("[WARN] Failed to delete blob %s of attachment %s: %v", key, attachment.ID, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"rtcs/internal/model"
+	"rtcs/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	chatRepo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	chats := NewChatService(chatRepo)
+	repo := NewMockRepository()
+	blobs, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+	attachments := NewAttachmentService(repo, blobs, chats, 1024)
+	messages := NewMessageService(repo, NewMockCache())
+	messages.SetBlobStore(blobs)
+
+	uploaderID := uuid.New()
+	chat, err := chats.CreateChat(ctx, "files", uploaderID)
+	if err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	memberID := uuid.New()
+	if err := chats.JoinChat(ctx, chat.ID, memberID); err != nil {
+		t.Fatalf("JoinChat failed: %v", err)
+	}
+	repo.AddUserToChat(ctx, chat.ID, uploaderID)
+	repo.AddUserToChat(ctx, chat.ID, memberID)
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	attachment, err := attachments.Upload(ctx, chat.ID, uploaderID, "../../photo.png", bytes.NewReader(png), int64(len(png)))
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if attachment.ContentType != "image/png" || attachment.FileName != "photo.png" {
+		t.Errorf("Expected a sniffed image/png named photo.png, got %s %q", attachment.ContentType, attachment.FileName)
+	}
+
+	t.Run("Upload limits", func(t *testing.T) {
+		big := make([]byte, 2048)
+		if _, err := attachments.Upload(ctx, chat.ID, uploaderID, "big", bytes.NewReader(big), int64(len(big))); !errors.Is(err, ErrAttachmentTooLarge) {
+			t.Errorf("Expected ErrAttachmentTooLarge, got %v", err)
+		}
+		// A chat's own limit replaces the default
+		chat.MaxAttachmentSize = 4096
+		defer func() { chat.MaxAttachmentSize = 0 }()
+		if _, err := attachments.Upload(ctx, chat.ID, uploaderID, "big", bytes.NewReader(big), int64(len(big))); err != nil {
+			t.Errorf("Expected the chat limit to allow the upload, got %v", err)
+		}
+		if _, err := attachments.Upload(ctx, chat.ID, uuid.New(), "x", bytes.NewReader(png), int64(len(png))); !errors.Is(err, ErrNotChatMember) {
+			t.Errorf("Expected ErrNotChatMember, got %v", err)
+		}
+	})
+
+	t.Run("Unsent attachments are private", func(t *testing.T) {
+		if _, _, err := attachments.Open(ctx, attachment.ID, memberID); !errors.Is(err, ErrAttachmentNotFound) {
+			t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+		}
+		if _, err := messages.SendWithAttachments(ctx, chat.ID.String(), memberID.String(), "", "", []string{attachment.ID.String()}); !errors.Is(err, ErrAttachmentNotFound) {
+			t.Errorf("Expected someone else's upload to be rejected, got %v", err)
+		}
+	})
+
+	message, err := messages.SendWithAttachments(ctx, chat.ID.String(), uploaderID.String(), "", "", []string{attachment.ID.String()})
+	if err != nil {
+		t.Fatalf("SendWithAttachments failed: %v", err)
+	}
+	if len(message.Attachments) != 1 || message.Attachments[0].MessageID == nil || *message.Attachments[0].MessageID != message.ID {
+		t.Fatalf("Expected the attachment linked to the message, got %+v", message.Attachments)
+	}
+
+	t.Run("Members download sent attachments", func(t *testing.T) {
+		_, content, err := attachments.Open(ctx, attachment.ID, memberID)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer content.Close()
+		data, _ := io.ReadAll(content)
+		if !bytes.Equal(data, png) {
+			t.Errorf("Expected the uploaded bytes back, got %d bytes", len(data))
+		}
+		if _, _, err := attachments.Open(ctx, attachment.ID, uuid.New()); !errors.Is(err, ErrNotChatMember) {
+			t.Errorf("Expected ErrNotChatMember, got %v", err)
+		}
+	})
+
+	t.Run("Deleting the message removes the file", func(t *testing.T) {
+		if err := messages.DeleteMessage(ctx, message.ID.String(), uploaderID.String()); err != nil {
+			t.Fatalf("DeleteMessage failed: %v", err)
+		}
+		if _, err := blobs.Get(ctx, attachment.StorageKey); !errors.Is(err, storage.ErrBlobNotFound) {
+			t.Errorf("Expected the blob to be deleted, got %v", err)
+		}
+	})
+}
+
+func TestAttachmentThumbnails(t *testing.T) {
+	ctx := context.Background()
+	chatRepo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	chats := NewChatService(chatRepo)
+	repo := NewMockRepository()
+	blobs, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+	attachments := NewAttachmentService(repo, blobs, chats, 1<<20)
+	messages := NewMessageService(repo, NewMockCache())
+	messages.SetBlobStore(blobs)
+
+	uploaderID := uuid.New()
+	chat, err := chats.CreateChat(ctx, "photos", uploaderID)
+	if err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	repo.AddUserToChat(ctx, chat.ID, uploaderID)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 400))); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	photo := buf.Bytes()
+	attachment, err := attachments.Upload(ctx, chat.ID, uploaderID, "photo.png", bytes.NewReader(photo), int64(len(photo)))
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if attachment.ThumbnailKey == "" || attachment.ThumbnailURL != attachment.URL+"/thumbnail" {
+		t.Fatalf("Expected a thumbnail, got key %q and URL %q", attachment.ThumbnailKey, attachment.ThumbnailURL)
+	}
+	if attachment.Width != 800 || attachment.Height != 400 {
+		t.Errorf("Expected 800x400, got %dx%d", attachment.Width, attachment.Height)
+	}
+
+	_, content, err := attachments.OpenThumbnail(ctx, attachment.ID, uploaderID)
+	if err != nil {
+		t.Fatalf("OpenThumbnail failed: %v", err)
+	}
+	thumb, err := jpeg.Decode(content)
+	content.Close()
+	if err != nil {
+		t.Fatalf("Expected a JPEG thumbnail: %v", err)
+	}
+	if got := thumb.Bounds().Size(); got != (image.Point{320, 160}) {
+		t.Errorf("Expected a 320x160 thumbnail, got %v", got)
+	}
+
+	t.Run("Other files have no thumbnail", func(t *testing.T) {
+		text := []byte("just some notes")
+		file, err := attachments.Upload(ctx, chat.ID, uploaderID, "notes.txt", bytes.NewReader(text), int64(len(text)))
+		if err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if file.ThumbnailKey != "" || file.ThumbnailURL != "" {
+			t.Errorf("Expected no thumbnail, got %q", file.ThumbnailKey)
+		}
+		if _, _, err := attachments.OpenThumbnail(ctx, file.ID, uploaderID); !errors.Is(err, ErrAttachmentNotFound) {
+			t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Deleting the message removes the thumbnail", func(t *testing.T) {
+		message, err := messages.SendWithAttachments(ctx, chat.ID.String(), uploaderID.String(), "", "", []string{attachment.ID.String()})
+		if err != nil {
+			t.Fatalf("SendWithAttachments failed: %v", err)
+		}
+		if err := messages.DeleteMessage(ctx, message.ID.String(), uploaderID.String()); err != nil {
+			t.Fatalf("DeleteMessage failed: %v", err)
+		}
+		if _, err := blobs.Get(ctx, attachment.ThumbnailKey); !errors.Is(err, storage.ErrBlobNotFound) {
+			t.Errorf("Expected the thumbnail to be deleted, got %v", err)
+		}
+	})
+}
@@ -3,8 +3,10 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"rtcs/internal/model"
 	"rtcs/internal/repository"
+	"sync"
 
 	"time"
 
@@ -13,10 +15,18 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// TicketStore remembers which WebSocket tickets have been redeemed
+type TicketStore interface {
+	// ClaimTicket records a ticket as redeemed, keeping the record for ttl.
+	// It reports false if the ticket was redeemed before.
+	ClaimTicket(ctx context.Context, ticketID string, ttl time.Duration) (bool, error)
+}
+
 // AuthService handles user authentication
 type AuthService struct {
 	userRepo  *repository.UserRepository
 	jwtSecret []byte
+	tickets   TicketStore
 }
 
 // NewAuthService creates a new authentication service
@@ -24,9 +34,26 @@ func NewAuthService(userRepo *repository.UserRepository, jwtSecret string) *Auth
 	return &AuthService{
 		userRepo:  userRepo,
 		jwtSecret: []byte(jwtSecret),
+		tickets:   newMemoryTicketStore(),
 	}
 }
 
+// SetTicketStore sets where redeemed WebSocket tickets are recorded, so that
+// a ticket cannot be replayed against another instance. Without one they
+// are only recorded in this process.
+func (s *AuthService) SetTicketStore(tickets TicketStore) {
+	s.tickets = tickets
+}
+
+const (
+	// webSocketTicketTTL is how long a WebSocket ticket stays valid. Tickets
+	// travel in the query string, so they are kept short-lived.
+	webSocketTicketTTL = 30 * time.Second
+	// webSocketTicketAudience marks tokens that are only good for opening a
+	// single WebSocket connection
+	webSocketTicketAudience = "rtcs-ws-ticket"
+)
+
 // LoginRequest represents the login request body
 type LoginRequest struct {
 	Username string `json:"username"`
@@ -75,6 +102,22 @@ func (s *AuthService) GenerateToken(userID string) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
+// GenerateWebSocketTicket issues a short-lived token that can be passed as
+// the ticket query parameter when opening a WebSocket connection
+func (s *AuthService) GenerateWebSocketTicket(userID string) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(webSocketTicketTTL)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{webSocketTicketAudience},
+		ID:        uuid.New().String(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
+
 // Login authenticates a user and returns a JWT token
 func (s *AuthService) Login(ctx context.Context, username, password string) (string, error) {
 	// Get user by username
@@ -124,26 +167,72 @@ func (s *AuthService) Register(ctx context.Context, username, password string) (
 	return user, nil
 }
 
+// ValidateToken verifies a JWT and returns the user ID it was issued for.
+// WebSocket tickets are not accepted.
 func (s *AuthService) ValidateToken(ctx context.Context, token string) (uuid.UUID, error) {
-	// Parse and validate token
-	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.VerifyAudience(webSocketTicketAudience, true) {
+		return uuid.Nil, errors.New("websocket tickets cannot be used as tokens")
+	}
+	return s.tokenUser(ctx, claims)
+}
+
+// ValidateWebSocketTicket verifies a ticket from GenerateWebSocketTicket and
+// returns the user ID it was issued for. Each ticket is accepted only once.
+func (s *AuthService) ValidateWebSocketTicket(ctx context.Context, ticket string) (uuid.UUID, error) {
+	claims, err := s.parseToken(ticket)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !claims.VerifyAudience(webSocketTicketAudience, true) || claims.ID == "" {
+		return uuid.Nil, ErrInvalidTicket
+	}
+
+	userID, err := s.tokenUser(ctx, claims)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	fresh, err := s.tickets.ClaimTicket(ctx, claims.ID, webSocketTicketTTL)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to redeem websocket ticket: %w", err)
+	}
+	if !fresh {
+		return uuid.Nil, ErrTicketUsed
+	}
+	return userID, nil
+}
+
+// parseToken verifies the signature and lifetime of a JWT
+func (s *AuthService) parseToken(token string) (*jwt.RegisteredClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
 		return s.jwtSecret, nil
 	})
 
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
 	if !parsedToken.Valid {
-		return uuid.Nil, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Get claims
-	claims, ok := parsedToken.Claims.(jwt.RegisteredClaims)
+	claims, ok := parsedToken.Claims.(*jwt.RegisteredClaims)
 	if !ok {
-		return uuid.Nil, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
+	return claims, nil
+}
 
+// tokenUser returns the existing user a token was issued for
+func (s *AuthService) tokenUser(ctx context.Context, claims *jwt.RegisteredClaims) (uuid.UUID, error) {
 	// Parse user ID from claims
 	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
@@ -158,3 +247,30 @@ func (s *AuthService) ValidateToken(ctx context.Context, token string) (uuid.UUI
 
 	return userID, nil
 }
+
+// memoryTicketStore records redeemed tickets in this process
+type memoryTicketStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newMemoryTicketStore() *memoryTicketStore {
+	return &memoryTicketStore{used: make(map[string]time.Time)}
+}
+
+func (m *memoryTicketStore) ClaimTicket(ctx context.Context, ticketID string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range m.used {
+		if now.After(expiresAt) {
+			delete(m.used, id)
+		}
+	}
+	if _, ok := m.used[ticketID]; ok {
+		return false, nil
+	}
+	m.used[ticketID] = now.Add(ttl)
+	return true, nil
+}
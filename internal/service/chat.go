@@ -2,7 +2,7 @@ package service
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	"rtcs/internal/model"
 	"rtcs/internal/repository"
@@ -10,27 +10,92 @@ import (
 	"github.com/google/uuid"
 )
 
+// ChatCache stores the activity summaries shown in chat lists
+type ChatCache interface {
+	GetChatSummary(ctx context.Context, chatID string) (*model.ChatSummary, error)
+	SetChatSummary(ctx context.Context, chatID string, summary *model.ChatSummary) error
+	GetUnreadCount(ctx context.Context, chatID, userID string) (*int64, error)
+	SetUnreadCount(ctx context.Context, chatID, userID string, count int64) error
+	DeleteUnreadCount(ctx context.Context, chatID, userID string) error
+}
+
 type ChatService struct {
-	repo repository.Repository
+	repo   repository.Repository
+	cache  ChatCache
+	events EventPublisher
+	access *ChatAccess
+
+	// Optional; fill in member listings
+	profiles ProfileLookup
+	presence PresenceLookup
 }
 
 func NewChatService(repo repository.Repository) *ChatService {
-	return &ChatService{repo: repo}
+	return &ChatService{repo: repo, access: NewChatAccess(repo, nil)}
+}
+
+// SetCache sets the cache for chat summaries and unread counts; without one
+// they are read from the database on every listing
+func (s *ChatService) SetCache(cache ChatCache) {
+	s.cache = cache
+}
+
+// SetChatAccess sets the membership checks, so that they can share a cache
+// with the message service; the cache is invalidated when memberships change
+func (s *ChatService) SetChatAccess(access *ChatAccess) {
+	s.access = access
+}
+
+// forgetMembership invalidates the cached memberships of users in a chat
+func (s *ChatService) forgetMembership(ctx context.Context, chatID uuid.UUID, userIDs ...uuid.UUID) {
+	for _, userID := range userIDs {
+		s.access.Forget(ctx, chatID, userID)
+		s.forgetUnreadCount(ctx, chatID, userID)
+	}
+}
+
+// SetEventPublisher sets where chat events are sent
+func (s *ChatService) SetEventPublisher(events EventPublisher) {
+	s.events = events
+}
+
+func (s *ChatService) publish(ctx context.Context, event Event) {
+	if s.events != nil {
+		s.events.Publish(ctx, event)
+	}
 }
 
 func (s *ChatService) CreateChat(ctx context.Context, name string, creatorID uuid.UUID) (*model.Chat, error) {
+	return s.CreateChatWithVisibility(ctx, name, creatorID, model.VisibilityPublic)
+}
+
+// CreateChatWithVisibility creates a chat owned by its creator that is
+// either open to anyone or joined only through invites
+func (s *ChatService) CreateChatWithVisibility(ctx context.Context, name string, creatorID uuid.UUID, visibility model.ChatVisibility) (*model.Chat, error) {
+	if !visibility.Valid() {
+		return nil, ErrInvalidVisibility
+	}
+
 	chatID := uuid.New()
 	chat := &model.Chat{
-		ID:   chatID,
-		Name: name,
+		ID:         chatID,
+		Name:       name,
+		CreatedBy:  &creatorID,
+		Visibility: visibility,
+		Kind:       model.KindChannel,
 	}
 
 	if err := s.repo.CreateChat(ctx, chat); err != nil {
 		return nil, err
 	}
 
-	// Add creator to the chat
-	if err := s.repo.AddUserToChat(ctx, chatID, creatorID); err != nil {
+	// The creator owns the chat
+	if err := s.repo.AddChatMember(ctx, &model.ChatUser{
+		ChatID:   chatID,
+		UserID:   creatorID,
+		JoinedAt: time.Now(),
+		Role:     model.RoleOwner,
+	}); err != nil {
 		return nil, err
 	}
 
@@ -41,10 +106,31 @@ func (s *ChatService) GetChat(ctx context.Context, id uuid.UUID) (*model.Chat, e
 	return s.repo.GetChat(ctx, id)
 }
 
+// GetChatForMember returns a chat to one of its members
+func (s *ChatService) GetChatForMember(ctx context.Context, chatID, userID uuid.UUID) (*model.Chat, error) {
+	chat, err := s.repo.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, ErrChatNotFound
+	}
+	if err := s.RequireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
 func (s *ChatService) ListChats(ctx context.Context, userID uuid.UUID) ([]*model.Chat, error) {
 	return s.repo.ListChats(ctx, userID)
 }
 
+// RequireMember returns ErrNotChatMember unless the user belongs to the chat
+func (s *ChatService) RequireMember(ctx context.Context, chatID, userID uuid.UUID) error {
+	return s.access.RequireMember(ctx, chatID, userID)
+}
+
+// JoinChat adds a user to a public chat. Joining again is a no-op.
 func (s *ChatService) JoinChat(ctx context.Context, chatID, userID uuid.UUID) error {
 	// Check if chat exists
 	chat, err := s.repo.GetChat(ctx, chatID)
@@ -52,10 +138,26 @@ func (s *ChatService) JoinChat(ctx context.Context, chatID, userID uuid.UUID) er
 		return err
 	}
 	if chat == nil {
-		return errors.New("chat not found")
+		return ErrChatNotFound
 	}
 
-	return s.repo.AddUserToChat(ctx, chatID, userID)
+	member, err := s.repo.GetChatMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if member != nil {
+		return nil
+	}
+	if chat.Visibility == model.VisibilityPrivate {
+		return ErrPrivateChat
+	}
+
+	if err := s.repo.AddUserToChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+	s.forgetMembership(ctx, chatID, userID)
+	s.publish(ctx, Event{Type: EventMemberAdded, ChatID: chatID, UserID: userID, TargetID: userID, Role: model.RoleMember})
+	return nil
 }
 
 func (s *ChatService) LeaveChat(ctx context.Context, chatID, userID uuid.UUID) error {
@@ -65,17 +167,25 @@ func (s *ChatService) LeaveChat(ctx context.Context, chatID, userID uuid.UUID) e
 		return err
 	}
 	if chat == nil {
-		return errors.New("chat not found")
+		return ErrChatNotFound
 	}
 
-	// Check if user is the creator by checking if they were the first to join
-	chatUsers, err := s.repo.ListChats(ctx, userID)
+	member, err := s.repo.GetChatMember(ctx, chatID, userID)
 	if err != nil {
 		return err
 	}
-	if len(chatUsers) > 0 && chatUsers[0].ID == chatID {
-		return errors.New("chat creator cannot leave the chat")
+	if member == nil {
+		return ErrNotChatMember
+	}
+	// A chat must always have an owner
+	if member.Role == model.RoleOwner {
+		return ErrOwnerCannotLeave
 	}
 
-	return s.repo.RemoveUserFromChat(ctx, chatID, userID)
+	if err := s.repo.RemoveUserFromChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+	s.forgetMembership(ctx, chatID, userID)
+	s.publish(ctx, Event{Type: EventMemberRemoved, ChatID: chatID, UserID: userID, TargetID: userID})
+	return nil
 }
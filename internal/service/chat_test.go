@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"rtcs/internal/model"
 	"rtcs/internal/repository"
@@ -14,11 +16,23 @@ type mockRepository struct {
 	repository.Repository
 	chats     map[uuid.UUID]*model.Chat
 	chatUsers map[uuid.UUID]map[uuid.UUID]bool
-	createErr error
-	getErr    error
-	listErr   error
-	addErr    error
-	removeErr error
+	messages  map[uuid.UUID]*model.Message
+	readSeq   map[uuid.UUID]int64
+	// Members of each chat in the order they joined
+	joined map[uuid.UUID][]uuid.UUID
+	roles  map[uuid.UUID]map[uuid.UUID]model.ChatRole
+	pins   map[uuid.UUID][]*model.ChatPin
+	// Invites by ID
+	invites map[uuid.UUID]*model.ChatInvite
+	// Number of GetLastMessage and CountUnreadMessages calls, to check
+	// caching
+	lastMessageCalls int
+	unreadCalls      int
+	createErr        error
+	getErr           error
+	listErr          error
+	addErr           error
+	removeErr        error
 }
 
 func (m *mockRepository) CreateChat(ctx context.Context, chat *model.Chat) error {
@@ -57,9 +71,188 @@ func (m *mockRepository) AddUserToChat(ctx context.Context, chatID, userID uuid.
 		m.chatUsers[chatID] = make(map[uuid.UUID]bool)
 	}
 	m.chatUsers[chatID][userID] = true
+	if m.joined == nil {
+		m.joined = make(map[uuid.UUID][]uuid.UUID)
+	}
+	m.joined[chatID] = append(m.joined[chatID], userID)
+	return m.SetMemberRole(ctx, chatID, userID, model.RoleMember)
+}
+
+func (m *mockRepository) AddChatMember(ctx context.Context, member *model.ChatUser) error {
+	if err := m.AddUserToChat(ctx, member.ChatID, member.UserID); err != nil {
+		return err
+	}
+	return m.SetMemberRole(ctx, member.ChatID, member.UserID, member.Role)
+}
+
+func (m *mockRepository) GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error) {
+	if !m.chatUsers[chatID][userID] {
+		return nil, nil
+	}
+	return &model.ChatUser{ChatID: chatID, UserID: userID, Role: m.roles[chatID][userID]}, nil
+}
+
+func (m *mockRepository) RenameChat(ctx context.Context, chatID uuid.UUID, name string) error {
+	m.chats[chatID].Name = name
+	return nil
+}
+
+func (m *mockRepository) SetChatVisibility(ctx context.Context, chatID uuid.UUID, visibility model.ChatVisibility) error {
+	m.chats[chatID].Visibility = visibility
+	return nil
+}
+
+func (m *mockRepository) SetMemberRole(ctx context.Context, chatID, userID uuid.UUID, role model.ChatRole) error {
+	if m.roles == nil {
+		m.roles = make(map[uuid.UUID]map[uuid.UUID]model.ChatRole)
+	}
+	if m.roles[chatID] == nil {
+		m.roles[chatID] = make(map[uuid.UUID]model.ChatRole)
+	}
+	m.roles[chatID][userID] = role
+	return nil
+}
+
+func (m *mockRepository) TransferOwnership(ctx context.Context, chatID, fromID, toID uuid.UUID) error {
+	m.roles[chatID][fromID] = model.RoleAdmin
+	m.roles[chatID][toID] = model.RoleOwner
+	return nil
+}
+
+func (m *mockRepository) ListChatMembers(ctx context.Context, chatID uuid.UUID) ([]*model.ChatUser, error) {
+	var members []*model.ChatUser
+	for _, userID := range m.joined[chatID] {
+		if m.chatUsers[chatID][userID] {
+			members = append(members, &model.ChatUser{ChatID: chatID, UserID: userID, Role: m.roles[chatID][userID]})
+		}
+	}
+	return members, nil
+}
+
+// ListMembersPage pages through ListChatMembers, which stands in for the
+// join times with the order members joined in
+func (m *mockRepository) ListMembersPage(ctx context.Context, chatID uuid.UUID, query model.MemberQuery) ([]*model.ChatUser, error) {
+	all, _ := m.ListChatMembers(ctx, chatID)
+	var members []*model.ChatUser
+	for i, member := range all {
+		member.JoinedAt = time.Unix(int64(i), 0)
+		if query.After != nil && !member.JoinedAt.After(query.After.JoinedAt) {
+			continue
+		}
+		if len(members) == query.Limit {
+			break
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (m *mockRepository) PinMessage(ctx context.Context, pin *model.ChatPin, maxPins int) (bool, error) {
+	for _, existing := range m.pins[pin.ChatID] {
+		if existing.MessageID == pin.MessageID {
+			return false, nil
+		}
+	}
+	if len(m.pins[pin.ChatID]) >= maxPins {
+		return false, repository.ErrPinLimitReached
+	}
+	if m.pins == nil {
+		m.pins = make(map[uuid.UUID][]*model.ChatPin)
+	}
+	m.pins[pin.ChatID] = append(m.pins[pin.ChatID], pin)
+	return true, nil
+}
+
+func (m *mockRepository) UnpinMessage(ctx context.Context, chatID, messageID uuid.UUID) (bool, error) {
+	for i, pin := range m.pins[chatID] {
+		if pin.MessageID == messageID {
+			m.pins[chatID] = append(m.pins[chatID][:i], m.pins[chatID][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockRepository) ListPins(ctx context.Context, chatID uuid.UUID) ([]*model.ChatPin, error) {
+	return m.pins[chatID], nil
+}
+
+func (m *mockRepository) FindOrCreateDirectChat(ctx context.Context, chat *model.Chat, opener *model.ChatUser, others []*model.ChatUser) (*model.Chat, bool, bool, error) {
+	created, rejoined := true, false
+	for _, existing := range m.chats {
+		if chat.DMKey != nil && existing.DMKey != nil && *existing.DMKey == *chat.DMKey {
+			chat, created = existing, false
+		}
+	}
+	m.chats[chat.ID] = chat
+	members := []*model.ChatUser{opener}
+	if created {
+		members = append(members, others...)
+	}
+	for _, member := range members {
+		if !m.chatUsers[chat.ID][member.UserID] {
+			member.ChatID = chat.ID
+			if err := m.AddChatMember(ctx, member); err != nil {
+				return nil, false, false, err
+			}
+			rejoined = rejoined || (member == opener && !created)
+		}
+	}
+	return chat, created, rejoined, nil
+}
+
+func (m *mockRepository) CreateInvite(ctx context.Context, invite *model.ChatInvite) error {
+	if m.invites == nil {
+		m.invites = make(map[uuid.UUID]*model.ChatInvite)
+	}
+	m.invites[invite.ID] = invite
+	return nil
+}
+
+func (m *mockRepository) GetInvite(ctx context.Context, inviteID uuid.UUID) (*model.ChatInvite, error) {
+	return m.invites[inviteID], nil
+}
+
+func (m *mockRepository) ListInvites(ctx context.Context, chatID uuid.UUID) ([]*model.ChatInvite, error) {
+	var invites []*model.ChatInvite
+	for _, invite := range m.invites {
+		if invite.ChatID == chatID {
+			invites = append(invites, invite)
+		}
+	}
+	return invites, nil
+}
+
+func (m *mockRepository) RevokeInvite(ctx context.Context, inviteID, revokedBy uuid.UUID, revokedAt time.Time) error {
+	m.invites[inviteID].RevokedAt = &revokedAt
+	m.invites[inviteID].RevokedBy = &revokedBy
 	return nil
 }
 
+func (m *mockRepository) AcceptInvite(ctx context.Context, tokenHash string, userID uuid.UUID, now time.Time) (*model.ChatInvite, bool, error) {
+	for _, invite := range m.invites {
+		if invite.TokenHash != tokenHash || invite.RevokedAt != nil {
+			continue
+		}
+		if !now.Before(invite.ExpiresAt) {
+			return nil, false, repository.ErrInviteExpired
+		}
+		if m.chatUsers[invite.ChatID][userID] {
+			return invite, false, nil
+		}
+		if invite.Uses >= invite.MaxUses {
+			return nil, false, repository.ErrInviteUsedUp
+		}
+		if err := m.AddUserToChat(ctx, invite.ChatID, userID); err != nil {
+			return nil, false, err
+		}
+		invite.Uses++
+		invite.Accepted = append(invite.Accepted, model.ChatInviteUse{InviteID: invite.ID, UserID: userID, UsedAt: now})
+		return invite, true, nil
+	}
+	return nil, false, repository.ErrInviteNotFound
+}
+
 func (m *mockRepository) RemoveUserFromChat(ctx context.Context, chatID, userID uuid.UUID) error {
 	if m.removeErr != nil {
 		return m.removeErr
@@ -68,6 +261,64 @@ func (m *mockRepository) RemoveUserFromChat(ctx context.Context, chatID, userID
 	return nil
 }
 
+func (m *mockRepository) GetMessage(ctx context.Context, id uuid.UUID) (*model.Message, error) {
+	return m.messages[id], nil
+}
+
+func (m *mockRepository) MarkDelivered(ctx context.Context, chatID, userID uuid.UUID, seq int64) (bool, error) {
+	return true, nil
+}
+
+func (m *mockRepository) MarkRead(ctx context.Context, chatID, userID, messageID uuid.UUID, seq int64) (bool, error) {
+	if m.readSeq[userID] >= seq {
+		return false, nil
+	}
+	m.readSeq[userID] = seq
+	return true, nil
+}
+
+func (m *mockRepository) ListMemberships(ctx context.Context, userID uuid.UUID) ([]*model.ChatUser, error) {
+	var memberships []*model.ChatUser
+	for id, chat := range m.chats {
+		if m.chatUsers[id][userID] {
+			memberships = append(memberships, &model.ChatUser{ChatID: id, UserID: userID, LastReadSeq: m.readSeq[userID], Chat: chat})
+		}
+	}
+	return memberships, nil
+}
+
+func (m *mockRepository) CountUnreadMessages(ctx context.Context, userID uuid.UUID, chatIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	m.unreadCalls++
+	wanted := make(map[uuid.UUID]bool, len(chatIDs))
+	for _, chatID := range chatIDs {
+		wanted[chatID] = true
+	}
+	counts := make(map[uuid.UUID]int64)
+	for _, message := range m.messages {
+		if !wanted[message.ChatID] || !m.chatUsers[message.ChatID][userID] || message.SenderID == userID {
+			continue
+		}
+		if message.ParentID == nil && message.DeletedAt == nil && message.Seq > m.readSeq[userID] {
+			counts[message.ChatID]++
+		}
+	}
+	return counts, nil
+}
+
+func (m *mockRepository) GetLastMessage(ctx context.Context, chatID uuid.UUID) (*model.Message, error) {
+	m.lastMessageCalls++
+	var last *model.Message
+	for _, message := range m.messages {
+		if message.ParentID != nil || message.DeletedAt != nil {
+			continue
+		}
+		if message.ChatID == chatID && (last == nil || message.Seq > last.Seq) {
+			last = message
+		}
+	}
+	return last, nil
+}
+
 func TestChatService_CreateChat(t *testing.T) {
 	ctx := context.Background()
 	repo := &mockRepository{
@@ -128,6 +379,31 @@ func TestChatService_JoinLeaveChat(t *testing.T) {
 	}
 }
 
+func TestChatService_GetChatForMember(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	service := NewChatService(repo)
+
+	ownerID := uuid.New()
+	chat, err := service.CreateChatWithVisibility(ctx, "secret", ownerID, model.VisibilityPrivate)
+	if err != nil {
+		t.Fatalf("CreateChatWithVisibility() error = %v", err)
+	}
+
+	if got, err := service.GetChatForMember(ctx, chat.ID, ownerID); err != nil || got.ID != chat.ID {
+		t.Errorf("GetChatForMember() for the owner = %v, %v", got, err)
+	}
+	if _, err := service.GetChatForMember(ctx, chat.ID, uuid.New()); err != ErrNotChatMember {
+		t.Errorf("GetChatForMember() for an outsider error = %v, want ErrNotChatMember", err)
+	}
+	if _, err := service.GetChatForMember(ctx, uuid.New(), ownerID); err != ErrChatNotFound {
+		t.Errorf("GetChatForMember() for an unknown chat error = %v, want ErrChatNotFound", err)
+	}
+}
+
 func TestChatService_CreatorCannotLeave(t *testing.T) {
 	ctx := context.Background()
 	repo := &mockRepository{
@@ -146,3 +422,714 @@ func TestChatService_CreatorCannotLeave(t *testing.T) {
 		t.Error("Expected error when creator tries to leave chat")
 	}
 }
+
+func TestChatService_MarkRead(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+		messages:  make(map[uuid.UUID]*model.Message),
+		readSeq:   make(map[uuid.UUID]int64),
+	}
+	publisher := &recordingPublisher{}
+	service := NewChatService(repo)
+	service.SetEventPublisher(publisher)
+
+	memberID := uuid.New()
+	chat, err := service.CreateChat(ctx, "test chat", memberID)
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+
+	first := &model.Message{ID: uuid.New(), ChatID: chat.ID, Seq: 1}
+	second := &model.Message{ID: uuid.New(), ChatID: chat.ID, Seq: 2}
+	foreign := &model.Message{ID: uuid.New(), ChatID: uuid.New(), Seq: 1}
+	for _, message := range []*model.Message{first, second, foreign} {
+		repo.messages[message.ID] = message
+	}
+
+	if err := service.MarkRead(ctx, chat.ID, uuid.New(), first.ID); err != ErrNotChatMember {
+		t.Errorf("MarkRead() by non-member error = %v, want %v", err, ErrNotChatMember)
+	}
+	if err := service.MarkRead(ctx, chat.ID, memberID, foreign.ID); err != ErrMessageNotFound {
+		t.Errorf("MarkRead() of message in another chat error = %v, want %v", err, ErrMessageNotFound)
+	}
+
+	if err := service.MarkRead(ctx, chat.ID, memberID, second.ID); err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+	// Acknowledging an older message does not move the position back
+	if err := service.MarkRead(ctx, chat.ID, memberID, first.ID); err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+
+	if len(publisher.events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(publisher.events))
+	}
+	event := publisher.events[0]
+	if event.Type != EventMessageRead || event.UserID != memberID || event.Message.Seq != 2 {
+		t.Errorf("Unexpected read event: %+v", event)
+	}
+}
+
+func TestChatService_ListChatOverviews(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+		messages:  make(map[uuid.UUID]*model.Message),
+		readSeq:   make(map[uuid.UUID]int64),
+	}
+	service := NewChatService(repo)
+	service.SetCache(NewMockCache())
+
+	userID := uuid.New()
+	quiet, _ := service.CreateChat(ctx, "quiet", userID)
+	busy, _ := service.CreateChat(ctx, "busy", userID)
+	quiet.CreatedAt = time.Now().Add(-time.Hour)
+
+	busy.LastSeq = 5
+	repo.readSeq[userID] = 1
+	otherID := uuid.New()
+	unread := &model.Message{ID: uuid.New(), ChatID: busy.ID, SenderID: otherID, Seq: 2, Text: "hi", CreatedAt: time.Now().Add(-time.Minute)}
+	last := &model.Message{ID: uuid.New(), ChatID: busy.ID, SenderID: otherID, Seq: 3, Text: strings.Repeat("a", 150), CreatedAt: time.Now()}
+	// Neither replies nor tombstones are unread or previewed
+	deletedAt := time.Now()
+	reply := &model.Message{ID: uuid.New(), ChatID: busy.ID, SenderID: otherID, ParentID: &last.ID, Seq: 4, Text: "reply", CreatedAt: time.Now()}
+	tombstone := &model.Message{ID: uuid.New(), ChatID: busy.ID, SenderID: otherID, Seq: 5, Text: model.DeletedMessageText, CreatedAt: time.Now(), DeletedAt: &deletedAt}
+	for _, message := range []*model.Message{unread, last, reply, tombstone} {
+		repo.messages[message.ID] = message
+	}
+
+	overviews, err := service.ListChatOverviews(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListChatOverviews() error = %v", err)
+	}
+	if len(overviews) != 2 {
+		t.Fatalf("Expected 2 chats, got %d", len(overviews))
+	}
+
+	// The most recently active chat comes first
+	first := overviews[0]
+	if first.ID != busy.ID {
+		t.Fatalf("Expected %s first, got %s", busy.Name, first.Name)
+	}
+	if first.UnreadCount != 2 {
+		t.Errorf("UnreadCount = %d, want 2", first.UnreadCount)
+	}
+	if first.LastMessage == nil || first.LastMessage.ID != last.ID {
+		t.Fatalf("Unexpected last message: %+v", first.LastMessage)
+	}
+	if n := len([]rune(first.LastMessage.Text)); n != previewLength+1 {
+		t.Errorf("Preview has %d characters, want %d", n, previewLength+1)
+	}
+	if !first.LastActivityAt.Equal(last.CreatedAt) {
+		t.Errorf("LastActivityAt = %v, want %v", first.LastActivityAt, last.CreatedAt)
+	}
+	if overviews[1].LastMessage != nil || overviews[1].UnreadCount != 0 {
+		t.Errorf("Expected empty chat without activity, got %+v", overviews[1])
+	}
+
+	// Summaries and unread counts are served from the cache on the next
+	// listing
+	calls, unreadCalls := repo.lastMessageCalls, repo.unreadCalls
+	if _, err := service.ListChatOverviews(ctx, userID); err != nil {
+		t.Fatalf("ListChatOverviews() error = %v", err)
+	}
+	if repo.lastMessageCalls != calls {
+		t.Errorf("Expected cached summaries, got %d more database lookups", repo.lastMessageCalls-calls)
+	}
+	if repo.unreadCalls != unreadCalls {
+		t.Errorf("Expected cached unread counts, got %d more database counts", repo.unreadCalls-unreadCalls)
+	}
+
+	// Reading invalidates the reader's count
+	if err := service.MarkRead(ctx, busy.ID, userID, unread.ID); err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+	overviews, err = service.ListChatOverviews(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListChatOverviews() error = %v", err)
+	}
+	if overviews[0].UnreadCount != 1 {
+		t.Errorf("UnreadCount after reading = %d, want 1", overviews[0].UnreadCount)
+	}
+}
+
+func TestChatService_Pins(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+		messages:  make(map[uuid.UUID]*model.Message),
+	}
+	publisher := &recordingPublisher{}
+	service := NewChatService(repo)
+	service.SetEventPublisher(publisher)
+
+	adminID := uuid.New()
+	chat, err := service.CreateChat(ctx, "announcements", adminID)
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	memberID := uuid.New()
+	if err := service.JoinChat(ctx, chat.ID, memberID); err != nil {
+		t.Fatalf("JoinChat() error = %v", err)
+	}
+	publisher.events = nil
+
+	newMessage := func(chatID uuid.UUID) *model.Message {
+		message := &model.Message{ID: uuid.New(), ChatID: chatID, SenderID: memberID, Text: "important"}
+		repo.messages[message.ID] = message
+		return message
+	}
+	message := newMessage(chat.ID)
+
+	if err := service.PinMessage(ctx, chat.ID, message.ID, memberID); err != ErrPermissionDenied {
+		t.Errorf("PinMessage() by member error = %v, want ErrPermissionDenied", err)
+	}
+	if err := service.PinMessage(ctx, chat.ID, message.ID, uuid.New()); err != ErrNotChatMember {
+		t.Errorf("PinMessage() by outsider error = %v, want ErrNotChatMember", err)
+	}
+	if err := service.PinMessage(ctx, chat.ID, newMessage(uuid.New()).ID, adminID); err != ErrMessageNotFound {
+		t.Errorf("PinMessage() of another chat's message error = %v, want ErrMessageNotFound", err)
+	}
+
+	if err := service.PinMessage(ctx, chat.ID, message.ID, adminID); err != nil {
+		t.Fatalf("PinMessage() error = %v", err)
+	}
+	// Pinning again changes nothing
+	if err := service.PinMessage(ctx, chat.ID, message.ID, adminID); err != nil {
+		t.Fatalf("PinMessage() again error = %v", err)
+	}
+	events := publisher.events
+	if len(events) != 1 || !events[0].Pinned || events[0].Message.ID != message.ID {
+		t.Fatalf("Expected one pin event for the message, got %+v", events)
+	}
+
+	pins, err := service.ListPins(ctx, chat.ID, memberID)
+	if err != nil {
+		t.Fatalf("ListPins() error = %v", err)
+	}
+	if len(pins) != 1 || pins[0].MessageID != message.ID || pins[0].PinnedBy != adminID {
+		t.Errorf("ListPins() = %+v, want the pinned message", pins)
+	}
+	if _, err := service.ListPins(ctx, chat.ID, uuid.New()); err != ErrNotChatMember {
+		t.Errorf("ListPins() by outsider error = %v, want ErrNotChatMember", err)
+	}
+
+	t.Run("Pins are capped per chat", func(t *testing.T) {
+		for i := 1; i < maxPinsPerChat; i++ {
+			if err := service.PinMessage(ctx, chat.ID, newMessage(chat.ID).ID, adminID); err != nil {
+				t.Fatalf("PinMessage() %d error = %v", i, err)
+			}
+		}
+		if err := service.PinMessage(ctx, chat.ID, newMessage(chat.ID).ID, adminID); err != ErrPinLimitReached {
+			t.Errorf("PinMessage() over the cap error = %v, want ErrPinLimitReached", err)
+		}
+	})
+
+	if err := service.UnpinMessage(ctx, chat.ID, message.ID, memberID); err != ErrPermissionDenied {
+		t.Errorf("UnpinMessage() by member error = %v, want ErrPermissionDenied", err)
+	}
+	if err := service.UnpinMessage(ctx, chat.ID, message.ID, adminID); err != nil {
+		t.Fatalf("UnpinMessage() error = %v", err)
+	}
+	events = publisher.events
+	if last := events[len(events)-1]; last.Pinned || last.Message.ID != message.ID {
+		t.Errorf("Expected an unpin event for the message, got %+v", last)
+	}
+}
+
+func TestChatService_Roles(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	publisher := &recordingPublisher{}
+	service := NewChatService(repo)
+	service.SetEventPublisher(publisher)
+
+	ownerID := uuid.New()
+	chat, err := service.CreateChat(ctx, "team", ownerID)
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	if chat.CreatedBy == nil || *chat.CreatedBy != ownerID {
+		t.Errorf("CreatedBy = %v, want %v", chat.CreatedBy, ownerID)
+	}
+	adminID, memberID := uuid.New(), uuid.New()
+	for _, userID := range []uuid.UUID{adminID, memberID} {
+		if err := service.AddMember(ctx, chat.ID, ownerID, userID); err != nil {
+			t.Fatalf("AddMember() error = %v", err)
+		}
+	}
+	if err := service.SetMemberRole(ctx, chat.ID, ownerID, adminID, model.RoleAdmin); err != nil {
+		t.Fatalf("SetMemberRole() error = %v", err)
+	}
+	if last := publisher.events[len(publisher.events)-1]; last.Type != EventRoleChanged || last.TargetID != adminID || last.Role != model.RoleAdmin {
+		t.Errorf("Expected a role event for the admin, got %+v", last)
+	}
+
+	t.Run("Members cannot manage the chat", func(t *testing.T) {
+		if err := service.RenameChat(ctx, chat.ID, memberID, "mine"); err != ErrPermissionDenied {
+			t.Errorf("RenameChat() error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.AddMember(ctx, chat.ID, memberID, uuid.New()); err != ErrPermissionDenied {
+			t.Errorf("AddMember() error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.RemoveMember(ctx, chat.ID, memberID, adminID); err != ErrPermissionDenied {
+			t.Errorf("RemoveMember() error = %v, want ErrPermissionDenied", err)
+		}
+	})
+
+	t.Run("Admins act only on lower roles", func(t *testing.T) {
+		if err := service.RenameChat(ctx, chat.ID, adminID, "  renamed  "); err != nil {
+			t.Fatalf("RenameChat() error = %v", err)
+		}
+		if chat.Name != "renamed" {
+			t.Errorf("Name = %q, want renamed", chat.Name)
+		}
+		if err := service.RenameChat(ctx, chat.ID, adminID, " "); err != ErrInvalidChatName {
+			t.Errorf("RenameChat(blank) error = %v, want ErrInvalidChatName", err)
+		}
+		if err := service.SetMemberRole(ctx, chat.ID, adminID, memberID, model.RoleReadOnly); err != nil {
+			t.Errorf("SetMemberRole(read_only) error = %v", err)
+		}
+		if err := service.SetMemberRole(ctx, chat.ID, adminID, memberID, model.RoleAdmin); err != ErrPermissionDenied {
+			t.Errorf("SetMemberRole(admin) by admin error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.SetMemberRole(ctx, chat.ID, adminID, memberID, model.RoleOwner); err != ErrInvalidRole {
+			t.Errorf("SetMemberRole(owner) error = %v, want ErrInvalidRole", err)
+		}
+		if err := service.RemoveMember(ctx, chat.ID, adminID, ownerID); err != ErrPermissionDenied {
+			t.Errorf("RemoveMember(owner) error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.RemoveMember(ctx, chat.ID, adminID, uuid.New()); err != ErrMemberNotFound {
+			t.Errorf("RemoveMember(stranger) error = %v, want ErrMemberNotFound", err)
+		}
+	})
+
+	t.Run("Ownership is transferred", func(t *testing.T) {
+		if err := service.LeaveChat(ctx, chat.ID, ownerID); err != ErrOwnerCannotLeave {
+			t.Errorf("LeaveChat() by owner error = %v, want ErrOwnerCannotLeave", err)
+		}
+		if err := service.TransferOwnership(ctx, chat.ID, adminID, memberID); err != ErrPermissionDenied {
+			t.Errorf("TransferOwnership() by admin error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.TransferOwnership(ctx, chat.ID, ownerID, adminID); err != nil {
+			t.Fatalf("TransferOwnership() error = %v", err)
+		}
+		if repo.roles[chat.ID][adminID] != model.RoleOwner || repo.roles[chat.ID][ownerID] != model.RoleAdmin {
+			t.Errorf("Roles after transfer = %v", repo.roles[chat.ID])
+		}
+		// The former owner is now an admin and may leave
+		if err := service.LeaveChat(ctx, chat.ID, ownerID); err != nil {
+			t.Errorf("LeaveChat() by former owner error = %v", err)
+		}
+	})
+
+	if err := service.RemoveMember(ctx, chat.ID, adminID, memberID); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+	if repo.chatUsers[chat.ID][memberID] {
+		t.Error("Expected the member to be removed")
+	}
+}
+
+type mockProfiles map[uuid.UUID]*model.UserProfile
+
+func (p mockProfiles) GetProfiles(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*model.UserProfile, error) {
+	profiles := make(map[uuid.UUID]*model.UserProfile)
+	for _, userID := range userIDs {
+		if profile, ok := p[userID]; ok {
+			profiles[userID] = profile
+		}
+	}
+	return profiles, nil
+}
+
+type mockPresence map[string]string
+
+func (p mockPresence) GetUserStatuses(ctx context.Context, userIDs []string) (map[string]string, error) {
+	return p, nil
+}
+
+func TestChatService_Members(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	publisher := &recordingPublisher{}
+	service := NewChatService(repo)
+	service.SetEventPublisher(publisher)
+
+	ownerID, aliceID, bobID := uuid.New(), uuid.New(), uuid.New()
+	profiles := mockProfiles{
+		ownerID: {ID: ownerID, Username: "owner"},
+		aliceID: {ID: aliceID, Username: "alice"},
+		bobID:   {ID: bobID, Username: "bob"},
+	}
+	service.SetProfileLookup(profiles)
+	service.SetPresenceLookup(mockPresence{aliceID.String(): "online"})
+
+	chat, err := service.CreateChat(ctx, "team", ownerID)
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	for _, userID := range []uuid.UUID{aliceID, bobID} {
+		if err := service.AddMember(ctx, chat.ID, ownerID, userID); err != nil {
+			t.Fatalf("AddMember() error = %v", err)
+		}
+	}
+	if len(publisher.events) != 2 || publisher.events[0].Type != EventMemberAdded || publisher.events[0].TargetID != aliceID || publisher.events[0].UserID != ownerID {
+		t.Errorf("Expected a member_added event per member, got %+v", publisher.events)
+	}
+	// Adding a member again changes nothing
+	if err := service.AddMember(ctx, chat.ID, ownerID, aliceID); err != nil || len(publisher.events) != 2 {
+		t.Errorf("AddMember() again error = %v, events = %d", err, len(publisher.events))
+	}
+	if err := service.AddMember(ctx, chat.ID, ownerID, uuid.New()); err != ErrUserNotFound {
+		t.Errorf("AddMember() of unknown user error = %v, want ErrUserNotFound", err)
+	}
+
+	t.Run("Members are listed in pages", func(t *testing.T) {
+		page, err := service.ListMembers(ctx, chat.ID, bobID, model.MemberQuery{Limit: 2})
+		if err != nil {
+			t.Fatalf("ListMembers() error = %v", err)
+		}
+		if len(page) != 2 || page[0].UserID != ownerID || page[1].UserID != aliceID {
+			t.Fatalf("Expected the owner then alice, got %+v", page)
+		}
+		if page[0].Role != model.RoleOwner || page[0].Status != "offline" || page[0].Profile.Username != "owner" {
+			t.Errorf("Unexpected owner listing %+v", page[0])
+		}
+		if page[1].Role != model.RoleMember || page[1].Status != "online" {
+			t.Errorf("Unexpected member listing %+v", page[1])
+		}
+
+		after := model.MemberCursorAt(page[1])
+		page, err = service.ListMembers(ctx, chat.ID, bobID, model.MemberQuery{After: &after, Limit: 2})
+		if err != nil {
+			t.Fatalf("ListMembers() error = %v", err)
+		}
+		if len(page) != 1 || page[0].UserID != bobID {
+			t.Errorf("Expected bob on the last page, got %+v", page)
+		}
+
+		if _, err := service.ListMembers(ctx, chat.ID, uuid.New(), model.MemberQuery{}); err != ErrNotChatMember {
+			t.Errorf("ListMembers() by outsider error = %v, want ErrNotChatMember", err)
+		}
+	})
+
+	publisher.events = nil
+	if err := service.RemoveMember(ctx, chat.ID, aliceID, bobID); err != ErrPermissionDenied {
+		t.Errorf("RemoveMember() by member error = %v, want ErrPermissionDenied", err)
+	}
+	if err := service.RemoveMember(ctx, chat.ID, ownerID, bobID); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+	if err := service.LeaveChat(ctx, chat.ID, aliceID); err != nil {
+		t.Fatalf("LeaveChat() error = %v", err)
+	}
+	events := publisher.events
+	if len(events) != 2 || events[0].Type != EventMemberRemoved || events[0].TargetID != bobID || events[0].UserID != ownerID ||
+		events[1].Type != EventMemberRemoved || events[1].TargetID != aliceID || events[1].UserID != aliceID {
+		t.Errorf("Expected member_removed events for the kick and the leave, got %+v", events)
+	}
+}
+
+func TestChatService_Invites(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	publisher := &recordingPublisher{}
+	service := NewChatService(repo)
+	service.SetEventPublisher(publisher)
+
+	ownerID, memberID, guestID := uuid.New(), uuid.New(), uuid.New()
+	chat, err := service.CreateChatWithVisibility(ctx, "private", ownerID, model.VisibilityPrivate)
+	if err != nil {
+		t.Fatalf("CreateChatWithVisibility() error = %v", err)
+	}
+	if _, err := service.CreateChatWithVisibility(ctx, "x", ownerID, "secret"); err != ErrInvalidVisibility {
+		t.Errorf("CreateChatWithVisibility(secret) error = %v, want ErrInvalidVisibility", err)
+	}
+	if err := service.JoinChat(ctx, chat.ID, guestID); err != ErrPrivateChat {
+		t.Fatalf("JoinChat() of private chat error = %v, want ErrPrivateChat", err)
+	}
+	if err := service.AddMember(ctx, chat.ID, ownerID, memberID); err != nil {
+		t.Fatalf("AddMember() error = %v", err)
+	}
+
+	t.Run("Only admins create invites", func(t *testing.T) {
+		if _, err := service.CreateInvite(ctx, chat.ID, memberID, time.Hour, 1); err != ErrPermissionDenied {
+			t.Errorf("CreateInvite() by member error = %v, want ErrPermissionDenied", err)
+		}
+		for _, limits := range []struct {
+			ttl     time.Duration
+			maxUses int
+		}{{0, 1}, {31 * 24 * time.Hour, 1}, {time.Hour, 0}, {time.Hour, 1001}} {
+			if _, err := service.CreateInvite(ctx, chat.ID, ownerID, limits.ttl, limits.maxUses); err != ErrInvalidInvite {
+				t.Errorf("CreateInvite(%v, %d) error = %v, want ErrInvalidInvite", limits.ttl, limits.maxUses, err)
+			}
+		}
+	})
+
+	invite, err := service.CreateInvite(ctx, chat.ID, ownerID, time.Hour, 1)
+	if err != nil {
+		t.Fatalf("CreateInvite() error = %v", err)
+	}
+	if invite.Token == "" || invite.TokenHash == invite.Token || invite.TokenHash != hashInviteToken(invite.Token) {
+		t.Fatalf("Expected a token stored only as its hash, got %+v", invite)
+	}
+
+	publisher.events = nil
+	joined, err := service.AcceptInvite(ctx, invite.Token, guestID)
+	if err != nil {
+		t.Fatalf("AcceptInvite() error = %v", err)
+	}
+	if joined.ID != chat.ID || !repo.chatUsers[chat.ID][guestID] {
+		t.Errorf("Expected the guest to join the chat")
+	}
+	if len(publisher.events) != 1 || publisher.events[0].Type != EventMemberAdded || publisher.events[0].UserID != ownerID || publisher.events[0].TargetID != guestID {
+		t.Errorf("Expected a member_added event from the inviter, got %+v", publisher.events)
+	}
+	// Members accepting again do not use the invite up
+	if _, err := service.AcceptInvite(ctx, invite.Token, guestID); err != nil {
+		t.Errorf("AcceptInvite() again error = %v", err)
+	}
+	if _, err := service.AcceptInvite(ctx, invite.Token, uuid.New()); err != ErrInviteUsedUp {
+		t.Errorf("AcceptInvite() beyond the limit error = %v, want ErrInviteUsedUp", err)
+	}
+	if _, err := service.AcceptInvite(ctx, "not-a-token", uuid.New()); err != ErrInviteNotFound {
+		t.Errorf("AcceptInvite() of unknown token error = %v, want ErrInviteNotFound", err)
+	}
+
+	t.Run("Expired and revoked invites are refused", func(t *testing.T) {
+		expired, err := service.CreateInvite(ctx, chat.ID, ownerID, time.Hour, 5)
+		if err != nil {
+			t.Fatalf("CreateInvite() error = %v", err)
+		}
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		if _, err := service.AcceptInvite(ctx, expired.Token, uuid.New()); err != ErrInviteExpired {
+			t.Errorf("AcceptInvite() of expired invite error = %v, want ErrInviteExpired", err)
+		}
+
+		revoked, err := service.CreateInvite(ctx, chat.ID, ownerID, time.Hour, 5)
+		if err != nil {
+			t.Fatalf("CreateInvite() error = %v", err)
+		}
+		if err := service.RevokeInvite(ctx, chat.ID, revoked.ID, memberID); err != ErrPermissionDenied {
+			t.Errorf("RevokeInvite() by member error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.RevokeInvite(ctx, uuid.New(), revoked.ID, ownerID); err != ErrNotChatMember {
+			t.Errorf("RevokeInvite() through another chat error = %v, want ErrNotChatMember", err)
+		}
+		if err := service.RevokeInvite(ctx, chat.ID, revoked.ID, ownerID); err != nil {
+			t.Fatalf("RevokeInvite() error = %v", err)
+		}
+		if revoked.RevokedBy == nil || *revoked.RevokedBy != ownerID {
+			t.Errorf("Expected the revoker to be recorded, got %v", revoked.RevokedBy)
+		}
+		if _, err := service.AcceptInvite(ctx, revoked.Token, uuid.New()); err != ErrInviteNotFound {
+			t.Errorf("AcceptInvite() of revoked invite error = %v, want ErrInviteNotFound", err)
+		}
+	})
+
+	invites, err := service.ListInvites(ctx, chat.ID, ownerID)
+	if err != nil {
+		t.Fatalf("ListInvites() error = %v", err)
+	}
+	audited := false
+	for _, listed := range invites {
+		if listed.ID == invite.ID {
+			audited = len(listed.Accepted) == 1 && listed.Accepted[0].UserID == guestID && listed.CreatedBy == ownerID
+		}
+	}
+	if !audited {
+		t.Errorf("Expected the invite to record who accepted it, got %+v", invites)
+	}
+
+	t.Run("Visibility changes", func(t *testing.T) {
+		if err := service.SetVisibility(ctx, chat.ID, memberID, model.VisibilityPublic); err != ErrPermissionDenied {
+			t.Errorf("SetVisibility() by member error = %v, want ErrPermissionDenied", err)
+		}
+		if err := service.SetVisibility(ctx, chat.ID, ownerID, model.VisibilityPublic); err != nil {
+			t.Fatalf("SetVisibility() error = %v", err)
+		}
+		if err := service.JoinChat(ctx, chat.ID, uuid.New()); err != nil {
+			t.Errorf("JoinChat() of public chat error = %v", err)
+		}
+	})
+}
+
+func TestChatService_DirectChats(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	publisher := &recordingPublisher{}
+	service := NewChatService(repo)
+	service.SetEventPublisher(publisher)
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	service.SetProfileLookup(mockProfiles{
+		alice: {ID: alice, Username: "alice"},
+		bob:   {ID: bob, Username: "bob"},
+		carol: {ID: carol, Username: "carol"},
+	})
+
+	chat, created, err := service.OpenDirectChat(ctx, alice, bob)
+	if err != nil || !created {
+		t.Fatalf("OpenDirectChat() = %v, %v", created, err)
+	}
+	if chat.Kind != model.KindDirect || chat.Visibility != model.VisibilityPrivate || chat.Name != "alice, bob" {
+		t.Errorf("Unexpected direct chat %+v", chat)
+	}
+	if repo.roles[chat.ID][alice] != model.RoleMember || repo.roles[chat.ID][bob] != model.RoleMember {
+		t.Errorf("Expected both users as plain members, got %v", repo.roles[chat.ID])
+	}
+	if len(publisher.events) != 1 || publisher.events[0].Type != EventMemberAdded || publisher.events[0].TargetID != bob {
+		t.Errorf("Expected bob to be told about the chat, got %+v", publisher.events)
+	}
+
+	// Either user opening the chat again finds the same one
+	again, created, err := service.OpenDirectChat(ctx, bob, alice)
+	if err != nil || created || again.ID != chat.ID {
+		t.Errorf("OpenDirectChat() again = %v, %v, %v; want the existing chat", again.ID, created, err)
+	}
+
+	// Reopening a chat only brings back the user opening it
+	if err := service.LeaveChat(ctx, chat.ID, bob); err != nil {
+		t.Fatalf("LeaveChat() error = %v", err)
+	}
+	if _, _, err := service.OpenDirectChat(ctx, alice, bob); err != nil {
+		t.Fatalf("OpenDirectChat() after leaving error = %v", err)
+	}
+	if err := service.RequireMember(ctx, chat.ID, bob); err != ErrNotChatMember {
+		t.Errorf("RequireMember() of bob after alice reopened = %v, want ErrNotChatMember", err)
+	}
+	publisher.events = nil
+	if _, _, err := service.OpenDirectChat(ctx, bob, alice); err != nil {
+		t.Fatalf("OpenDirectChat() by bob error = %v", err)
+	}
+	if len(publisher.events) != 1 || publisher.events[0].Type != EventMemberAdded || publisher.events[0].TargetID != bob {
+		t.Errorf("Expected bob's rejoining to be announced, got %+v", publisher.events)
+	}
+	if err := service.RequireMember(ctx, chat.ID, bob); err != nil {
+		t.Errorf("RequireMember() of bob after reopening = %v, want nil", err)
+	}
+
+	if _, _, err := service.OpenDirectChat(ctx, alice, alice); err != ErrInvalidDirectChat {
+		t.Errorf("OpenDirectChat() with oneself error = %v, want ErrInvalidDirectChat", err)
+	}
+	if _, _, err := service.OpenDirectChat(ctx, alice, uuid.New()); err != ErrUserNotFound {
+		t.Errorf("OpenDirectChat() with unknown user error = %v, want ErrUserNotFound", err)
+	}
+	if err := service.RenameChat(ctx, chat.ID, alice, "ours"); err != ErrPermissionDenied {
+		t.Errorf("RenameChat() of direct chat error = %v, want ErrPermissionDenied", err)
+	}
+
+	t.Run("Group direct chats", func(t *testing.T) {
+		group, err := service.CreateGroupDM(ctx, alice, []uuid.UUID{bob, carol, bob})
+		if err != nil {
+			t.Fatalf("CreateGroupDM() error = %v", err)
+		}
+		if group.Kind != model.KindGroup || group.DMKey != nil || len(repo.chatUsers[group.ID]) != 3 {
+			t.Errorf("Unexpected group chat %+v with members %v", group, repo.chatUsers[group.ID])
+		}
+		if second, _ := service.CreateGroupDM(ctx, alice, []uuid.UUID{bob, carol}); second.ID == group.ID {
+			t.Error("Expected every group direct chat to be new")
+		}
+
+		if _, err := service.CreateGroupDM(ctx, alice, []uuid.UUID{alice}); err != ErrInvalidDirectChat {
+			t.Errorf("CreateGroupDM() alone error = %v, want ErrInvalidDirectChat", err)
+		}
+		crowd := make([]uuid.UUID, MaxGroupDMMembers)
+		for i := range crowd {
+			crowd[i] = uuid.New()
+		}
+		if _, err := service.CreateGroupDM(ctx, alice, crowd); err != ErrTooManyMembers {
+			t.Errorf("CreateGroupDM() over the limit error = %v, want ErrTooManyMembers", err)
+		}
+	})
+}
+
+func TestChatService_MembershipCache(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepository{
+		chats:     make(map[uuid.UUID]*model.Chat),
+		chatUsers: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+	cache := NewMockCache()
+	access := NewChatAccess(repo, cache)
+	service := NewChatService(repo)
+	service.SetChatAccess(access)
+
+	ownerID, userID := uuid.New(), uuid.New()
+	chat, err := service.CreateChat(ctx, "cached", ownerID)
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	roleKey := chat.ID.String() + "|" + userID.String()
+
+	if err := access.RequireMember(ctx, chat.ID, userID); err != ErrNotChatMember {
+		t.Fatalf("RequireMember() before joining error = %v, want ErrNotChatMember", err)
+	}
+	if role, ok := cache.roles[roleKey]; !ok || role != "" {
+		t.Errorf("Expected the missing membership to be cached, got %q, %v", role, ok)
+	}
+
+	// Cached lookups do not reach the repository
+	repo.chatUsers[chat.ID][userID] = true
+	if err := access.RequireMember(ctx, chat.ID, userID); err != ErrNotChatMember {
+		t.Errorf("RequireMember() error = %v, want the cached ErrNotChatMember", err)
+	}
+	delete(repo.chatUsers[chat.ID], userID)
+
+	if err := service.JoinChat(ctx, chat.ID, userID); err != nil {
+		t.Fatalf("JoinChat() error = %v", err)
+	}
+	if err := access.Require(ctx, chat.ID, userID, ActionSendMessages); err != nil {
+		t.Errorf("Require() after joining error = %v", err)
+	}
+
+	if err := service.SetMemberRole(ctx, chat.ID, ownerID, userID, model.RoleReadOnly); err != nil {
+		t.Fatalf("SetMemberRole() error = %v", err)
+	}
+	if err := access.Require(ctx, chat.ID, userID, ActionSendMessages); err != ErrPermissionDenied {
+		t.Errorf("Require() for a read-only member error = %v, want ErrPermissionDenied", err)
+	}
+
+	if err := service.RemoveMember(ctx, chat.ID, ownerID, userID); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+	if err := access.RequireMember(ctx, chat.ID, userID); err != ErrNotChatMember {
+		t.Errorf("RequireMember() after a kick error = %v, want ErrNotChatMember", err)
+	}
+	if _, err := service.ListPins(ctx, chat.ID, userID); err != ErrNotChatMember {
+		t.Errorf("ListPins() after a kick error = %v, want ErrNotChatMember", err)
+	}
+	if _, err := service.ListMembers(ctx, chat.ID, userID, model.MemberQuery{}); err != ErrNotChatMember {
+		t.Errorf("ListMembers() after a kick error = %v, want ErrNotChatMember", err)
+	}
+
+	if err := service.JoinChat(ctx, chat.ID, userID); err != nil {
+		t.Fatalf("JoinChat() error = %v", err)
+	}
+	if err := access.RequireMember(ctx, chat.ID, userID); err != nil {
+		t.Fatalf("RequireMember() after rejoining error = %v", err)
+	}
+	if err := service.LeaveChat(ctx, chat.ID, userID); err != nil {
+		t.Fatalf("LeaveChat() error = %v", err)
+	}
+	if err := access.RequireMember(ctx, chat.ID, userID); err != ErrNotChatMember {
+		t.Errorf("RequireMember() after leaving error = %v, want ErrNotChatMember", err)
+	}
+}
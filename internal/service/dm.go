@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// MaxGroupDMMembers bounds the number of users in a group direct chat,
+// its creator included
+const MaxGroupDMMembers = 10
+
+// OpenDirectChat returns the direct chat between two users, creating it the
+// first time either of them opens it, and reports whether it was created
+func (s *ChatService) OpenDirectChat(ctx context.Context, userID, otherID uuid.UUID) (*model.Chat, bool, error) {
+	if otherID == userID {
+		return nil, false, ErrInvalidDirectChat
+	}
+	key := model.DirectMessageKey(userID, otherID)
+	return s.createDirectChat(ctx, model.KindDirect, &key, userID, []uuid.UUID{otherID})
+}
+
+// CreateGroupDM creates a direct chat between the user and up to
+// MaxGroupDMMembers-1 others. Every call creates a new chat.
+func (s *ChatService) CreateGroupDM(ctx context.Context, userID uuid.UUID, otherIDs []uuid.UUID) (*model.Chat, error) {
+	seen := map[uuid.UUID]bool{userID: true}
+	var others []uuid.UUID
+	for _, otherID := range otherIDs {
+		if !seen[otherID] {
+			seen[otherID] = true
+			others = append(others, otherID)
+		}
+	}
+	if len(others) == 0 {
+		return nil, ErrInvalidDirectChat
+	}
+	if len(others)+1 > MaxGroupDMMembers {
+		return nil, ErrTooManyMembers
+	}
+
+	chat, _, err := s.createDirectChat(ctx, model.KindGroup, nil, userID, others)
+	return chat, err
+}
+
+// createDirectChat finds or creates a private chat in which the user and
+// the others are all plain members, so none of them can change it
+func (s *ChatService) createDirectChat(ctx context.Context, kind model.ChatKind, key *string, userID uuid.UUID, otherIDs []uuid.UUID) (*model.Chat, bool, error) {
+	userIDs := append([]uuid.UUID{userID}, otherIDs...)
+	name := "Direct message"
+	if s.profiles != nil {
+		profiles, err := s.profiles.GetProfiles(ctx, userIDs)
+		if err != nil {
+			return nil, false, err
+		}
+		names := make([]string, len(userIDs))
+		for i, id := range userIDs {
+			if profiles[id] == nil {
+				return nil, false, ErrUserNotFound
+			}
+			names[i] = profiles[id].Username
+		}
+		name = strings.Join(names, ", ")
+		if runes := []rune(name); len(runes) > maxChatNameRunes {
+			name = string(runes[:maxChatNameRunes])
+		}
+	}
+
+	now := time.Now()
+	opener := &model.ChatUser{UserID: userID, JoinedAt: now, Role: model.RoleMember}
+	others := make([]*model.ChatUser, len(otherIDs))
+	for i, id := range otherIDs {
+		others[i] = &model.ChatUser{UserID: id, JoinedAt: now, Role: model.RoleMember}
+	}
+	chat, created, rejoined, err := s.repo.FindOrCreateDirectChat(ctx, &model.Chat{
+		ID:         uuid.New(),
+		Name:       name,
+		CreatedBy:  &userID,
+		Visibility: model.VisibilityPrivate,
+		Kind:       kind,
+		DMKey:      key,
+	}, opener, others)
+	if err != nil {
+		return nil, false, err
+	}
+	// Reopening a direct chat only brings back the user opening it; the
+	// others rejoin by opening it themselves
+	s.forgetMembership(ctx, chat.ID, userID)
+
+	if created {
+		s.forgetMembership(ctx, chat.ID, otherIDs...)
+		// Tell the others about the new chat
+		for _, otherID := range otherIDs {
+			s.publish(ctx, Event{Type: EventMemberAdded, ChatID: chat.ID, UserID: userID, TargetID: otherID, Role: model.RoleMember})
+		}
+	}
+	if rejoined {
+		// Subscribes the user's open connections to the chat again
+		s.publish(ctx, Event{Type: EventMemberAdded, ChatID: chat.ID, UserID: userID, TargetID: userID, Role: model.RoleMember})
+	}
+	return chat, created, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import "errors"
+
+var (
+	// ErrNotChatMember is returned when a user acts on a chat they do not belong to
+	ErrNotChatMember = errors.New("user is not a member of this chat")
+	// ErrMessageNotFound is returned when a message does not exist in the chat
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrNotMessageSender is returned when a user changes or deletes someone
+	// else's message
+	ErrNotMessageSender = errors.New("user is not the sender of this message")
+	// ErrInvalidReaction is returned for an empty or oversized reaction emoji
+	ErrInvalidReaction = errors.New("invalid reaction emoji")
+	// ErrInvalidSearch is returned for a search without text or with
+	// contradictory filters
+	ErrInvalidSearch = errors.New("invalid search")
+	// ErrAttachmentNotFound is returned for attachments that do not exist or
+	// cannot be used by the caller
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentTooLarge is returned for uploads over the chat's limit
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrInvalidAttachment is returned for empty uploads
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	// ErrPermissionDenied is returned when a member's role does not allow
+	// an action in the chat
+	ErrPermissionDenied = errors.New("not permitted in this chat")
+	// ErrMemberNotFound is returned when acting on a user who is not a
+	// member of the chat
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrUserNotFound is returned when adding a user who does not exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidRole is returned for unknown roles and for assigning the
+	// owner role other than by transfer
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrOwnerCannotLeave is returned when the owner leaves a chat without
+	// transferring it first
+	ErrOwnerCannotLeave = errors.New("the owner must transfer the chat before leaving it")
+	// ErrInvalidTicket is returned when a token other than a WebSocket
+	// ticket is offered as one
+	ErrInvalidTicket = errors.New("invalid websocket ticket")
+	// ErrTicketUsed is returned when a WebSocket ticket is redeemed again
+	ErrTicketUsed = errors.New("websocket ticket already used")
+	// ErrChatNotFound is returned for chats that do not exist
+	ErrChatNotFound = errors.New("chat not found")
+	// ErrPrivateChat is returned when joining a private chat without an invite
+	ErrPrivateChat = errors.New("private chats are joined through an invite")
+	// ErrInvalidDirectChat is returned for direct chats without anyone
+	// besides the caller
+	ErrInvalidDirectChat = errors.New("a direct chat needs another user")
+	// ErrTooManyMembers is returned for group direct chats over the size limit
+	ErrTooManyMembers = errors.New("too many members for a group direct chat")
+	// ErrInvalidVisibility is returned for unknown chat visibilities
+	ErrInvalidVisibility = errors.New("invalid chat visibility")
+	// ErrInvalidInvite is returned for invites with an expiry or use limit
+	// out of range
+	ErrInvalidInvite = errors.New("invalid invite")
+	// ErrInviteNotFound is returned for invites that do not exist or have
+	// been revoked
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrInviteExpired is returned for invites past their expiry
+	ErrInviteExpired = errors.New("invite expired")
+	// ErrInviteUsedUp is returned for invites accepted as often as allowed
+	ErrInviteUsedUp = errors.New("invite has no uses left")
+	// ErrInvalidChatName is returned for empty or oversized chat names
+	ErrInvalidChatName = errors.New("invalid chat name")
+	// ErrPinLimitReached is returned when pinning to a chat that already has
+	// the most pins allowed
+	ErrPinLimitReached = errors.New("chat has too many pinned messages")
+	// ErrInvalidImage is returned for avatars that are not a JPEG, PNG or
+	// GIF image of acceptable dimensions
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrAvatarNotFound is returned for avatar files that do not exist
+	ErrAvatarNotFound = errors.New("avatar not found")
+)
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Event types emitted by the services
+const (
+	EventMessageCreated   = "message_created"
+	EventMessageEdited    = "message_edited"
+	EventMessageDeleted   = "message_deleted"
+	EventPresenceChanged  = "presence_changed"
+	EventMessageDelivered = "message_delivered"
+	EventMessageRead      = "message_read"
+	EventThreadReply      = "thread_reply"
+	EventReactionAdded    = "reaction_added"
+	EventReactionRemoved  = "reaction_removed"
+	EventPinsChanged      = "pins_changed"
+	EventMention          = "mention"
+	EventChatRenamed      = "chat_renamed"
+	EventRoleChanged      = "member_role_changed"
+	EventMemberAdded      = "member_added"
+	EventMemberRemoved    = "member_removed"
+)
+
+// Event is a domain event emitted after a change has been persisted
+type Event struct {
+	Type    string
+	ChatID  uuid.UUID
+	Message *model.Message
+	UserID  uuid.UUID   // Set for presence, receipt and reaction events
+	Status  string      // "online" or "offline" for presence events
+	UserIDs []uuid.UUID // Recipients of events addressed to specific users
+	Emoji   string      // Set for reaction events
+	Pinned  bool        // Set for pin events: pinned rather than unpinned
+
+	// Set for chat events: the new name of a renamed chat, or the member
+	// UserID acted on and their new role. Members joining or leaving on
+	// their own act on themselves.
+	Name     string
+	TargetID uuid.UUID
+	Role     model.ChatRole
+}
+
+// EventPublisher receives domain events from the services
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event)
+}
+
+// EventHandler consumes a domain event
+type EventHandler func(ctx context.Context, event Event)
+
+// EventBus is an in-process EventPublisher that fans events out to subscribers
+type EventBus struct {
+	handlers []EventHandler
+	mutex    sync.RWMutex
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe registers a handler for all subsequent events
+func (b *EventBus) Subscribe(handler EventHandler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish delivers an event synchronously to every subscriber
+func (b *EventBus) Publish(ctx context.Context, event Event) {
+	b.mutex.RLock()
+	handlers := make([]EventHandler, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
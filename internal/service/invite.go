@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"rtcs/internal/model"
+	"rtcs/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultInviteTTL is how long invites stay valid unless set otherwise
+	DefaultInviteTTL = 7 * 24 * time.Hour
+	// maxInviteTTL bounds how long an invite may stay valid
+	maxInviteTTL = 30 * 24 * time.Hour
+	// maxInviteUses bounds how many users may join through one invite
+	maxInviteUses = 1000
+	// inviteTokenBytes is the amount of randomness in an invite token
+	inviteTokenBytes = 24
+)
+
+// CreateInvite issues an invite to a chat that up to maxUses users may
+// accept within ttl. The token is only returned here; the server keeps a
+// hash of it.
+func (s *ChatService) CreateInvite(ctx context.Context, chatID, userID uuid.UUID, ttl time.Duration, maxUses int) (*model.ChatInvite, error) {
+	if ttl <= 0 || ttl > maxInviteTTL || maxUses < 1 || maxUses > maxInviteUses {
+		return nil, ErrInvalidInvite
+	}
+	if _, err := s.authorize(ctx, chatID, userID, ActionInvite); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, inviteTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	invite := &model.ChatInvite{
+		ID:        uuid.New(),
+		ChatID:    chatID,
+		TokenHash: hashInviteToken(token),
+		CreatedBy: userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxUses:   maxUses,
+	}
+	if err := s.repo.CreateInvite(ctx, invite); err != nil {
+		return nil, err
+	}
+	invite.Token = token
+	return invite, nil
+}
+
+// ListInvites returns a chat's invites, revoked and expired ones included,
+// with the users who joined through each
+func (s *ChatService) ListInvites(ctx context.Context, chatID, userID uuid.UUID) ([]*model.ChatInvite, error) {
+	if _, err := s.authorize(ctx, chatID, userID, ActionInvite); err != nil {
+		return nil, err
+	}
+	return s.repo.ListInvites(ctx, chatID)
+}
+
+// RevokeInvite stops an invite from being accepted. Revoking it again is a
+// no-op.
+func (s *ChatService) RevokeInvite(ctx context.Context, chatID, inviteID, userID uuid.UUID) error {
+	if _, err := s.authorize(ctx, chatID, userID, ActionInvite); err != nil {
+		return err
+	}
+
+	invite, err := s.repo.GetInvite(ctx, inviteID)
+	if err != nil {
+		return err
+	}
+	if invite == nil || invite.ChatID != chatID {
+		return ErrInviteNotFound
+	}
+	if invite.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.RevokeInvite(ctx, inviteID, userID, time.Now())
+}
+
+// AcceptInvite adds the user to the chat an invite is for and returns the
+// chat. Members accepting an invite to their own chat do not use it up.
+func (s *ChatService) AcceptInvite(ctx context.Context, token string, userID uuid.UUID) (*model.Chat, error) {
+	invite, joined, err := s.repo.AcceptInvite(ctx, hashInviteToken(token), userID, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrInviteNotFound):
+		return nil, ErrInviteNotFound
+	case errors.Is(err, repository.ErrInviteExpired):
+		return nil, ErrInviteExpired
+	case errors.Is(err, repository.ErrInviteUsedUp):
+		return nil, ErrInviteUsedUp
+	case err != nil:
+		return nil, err
+	}
+
+	if joined {
+		s.forgetMembership(ctx, invite.ChatID, userID)
+		s.publish(ctx, Event{Type: EventMemberAdded, ChatID: invite.ChatID, UserID: invite.CreatedBy, TargetID: userID, Role: model.RoleMember})
+	}
+	return s.repo.GetChat(ctx, invite.ChatID)
+}
+
+// hashInviteToken returns the form of an invite token kept in the database
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
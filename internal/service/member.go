@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rtcs/internal/model"
+	"rtcs/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxChatNameRunes bounds chat names to their column
+	maxChatNameRunes = 255
+	// defaultMemberLimit is the page size of member listings
+	defaultMemberLimit = 50
+)
+
+// ProfileLookup finds the public profiles of users
+type ProfileLookup interface {
+	GetProfiles(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*model.UserProfile, error)
+}
+
+// PresenceLookup reports whether users are online
+type PresenceLookup interface {
+	GetUserStatuses(ctx context.Context, userIDs []string) (map[string]string, error)
+}
+
+// SetProfileLookup sets where member profiles come from. Without one,
+// listings leave profiles out and members are added without checking that
+// the user exists.
+func (s *ChatService) SetProfileLookup(profiles ProfileLookup) {
+	s.profiles = profiles
+}
+
+// SetPresenceLookup sets where member presence comes from; without one
+// every member is listed as offline
+func (s *ChatService) SetPresenceLookup(presence PresenceLookup) {
+	s.presence = presence
+}
+
+// ListMembers returns a page of a chat's members, in the order they joined,
+// with their profiles and presence. Only members may list a chat.
+func (s *ChatService) ListMembers(ctx context.Context, chatID, userID uuid.UUID, query model.MemberQuery) ([]*model.ChatMember, error) {
+	if err := s.RequireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultMemberLimit
+	}
+
+	memberships, err := s.repo.ListMembersPage(ctx, chatID, query)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]uuid.UUID, len(memberships))
+	for i, membership := range memberships {
+		userIDs[i] = membership.UserID
+	}
+
+	var profiles map[uuid.UUID]*model.UserProfile
+	if s.profiles != nil && len(userIDs) > 0 {
+		if profiles, err = s.profiles.GetProfiles(ctx, userIDs); err != nil {
+			return nil, err
+		}
+	}
+	statuses := s.memberStatuses(ctx, userIDs)
+
+	members := make([]*model.ChatMember, len(memberships))
+	for i, membership := range memberships {
+		status := statuses[membership.UserID.String()]
+		if status == "" {
+			status = "offline"
+		}
+		members[i] = &model.ChatMember{
+			UserID:    membership.UserID,
+			Role:      membership.Role,
+			JoinedAt:  membership.JoinedAt,
+			InvitedBy: membership.InvitedBy,
+			Status:    status,
+			Profile:   profiles[membership.UserID],
+		}
+	}
+	return members, nil
+}
+
+// memberStatuses looks up the presence of members. Presence is advisory, so
+// a failed lookup lists everyone as offline rather than failing the listing.
+func (s *ChatService) memberStatuses(ctx context.Context, userIDs []uuid.UUID) map[string]string {
+	if s.presence == nil || len(userIDs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		ids[i] = userID.String()
+	}
+	statuses, err := s.presence.GetUserStatuses(ctx, ids)
+	if err != nil {
+		log.Printf("[WARN] Failed to look up presence of chat members: %v", err)
+		return nil
+	}
+	return statuses
+}
+
+// RenameChat changes the name of a chat
+func (s *ChatService) RenameChat(ctx context.Context, chatID, userID uuid.UUID, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxChatNameRunes {
+		return ErrInvalidChatName
+	}
+	if _, err := s.authorize(ctx, chatID, userID, ActionRename); err != nil {
+		return err
+	}
+
+	if err := s.repo.RenameChat(ctx, chatID, name); err != nil {
+		return err
+	}
+	s.publish(ctx, Event{Type: EventChatRenamed, ChatID: chatID, UserID: userID, Name: name})
+	return nil
+}
+
+// SetVisibility changes who may join a chat without an invite
+func (s *ChatService) SetVisibility(ctx context.Context, chatID, userID uuid.UUID, visibility model.ChatVisibility) error {
+	if !visibility.Valid() {
+		return ErrInvalidVisibility
+	}
+	if _, err := s.authorize(ctx, chatID, userID, ActionSetVisibility); err != nil {
+		return err
+	}
+	return s.repo.SetChatVisibility(ctx, chatID, visibility)
+}
+
+// AddMember adds a user to a chat on behalf of a member allowed to invite.
+// Adding a member again is a no-op.
+func (s *ChatService) AddMember(ctx context.Context, chatID, actorID, userID uuid.UUID) error {
+	if _, err := s.authorize(ctx, chatID, actorID, ActionInvite); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetChatMember(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	if s.profiles != nil {
+		profiles, err := s.profiles.GetProfiles(ctx, []uuid.UUID{userID})
+		if err != nil {
+			return err
+		}
+		if profiles[userID] == nil {
+			return ErrUserNotFound
+		}
+	}
+
+	if err := s.repo.AddChatMember(ctx, &model.ChatUser{
+		ChatID:    chatID,
+		UserID:    userID,
+		JoinedAt:  time.Now(),
+		Role:      model.RoleMember,
+		InvitedBy: &actorID,
+	}); err != nil {
+		return err
+	}
+	s.forgetMembership(ctx, chatID, userID)
+	s.publish(ctx, Event{Type: EventMemberAdded, ChatID: chatID, UserID: actorID, TargetID: userID, Role: model.RoleMember})
+	return nil
+}
+
+// RemoveMember removes a member the actor outranks from a chat
+func (s *ChatService) RemoveMember(ctx context.Context, chatID, actorID, userID uuid.UUID) error {
+	if _, _, err := s.authorizeOver(ctx, chatID, actorID, userID, ActionKick); err != nil {
+		return err
+	}
+	if err := s.repo.RemoveUserFromChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+	s.forgetMembership(ctx, chatID, userID)
+	s.publish(ctx, Event{Type: EventMemberRemoved, ChatID: chatID, UserID: actorID, TargetID: userID})
+	return nil
+}
+
+// SetMemberRole changes the role of a member the actor outranks to a role
+// below the actor's own. The owner role only changes hands through
+// TransferOwnership.
+func (s *ChatService) SetMemberRole(ctx context.Context, chatID, actorID, userID uuid.UUID, role model.ChatRole) error {
+	if !role.Valid() || role == model.RoleOwner {
+		return ErrInvalidRole
+	}
+	actor, target, err := s.authorizeOver(ctx, chatID, actorID, userID, ActionManageRoles)
+	if err != nil {
+		return err
+	}
+	if !actor.Role.Outranks(role) {
+		return ErrPermissionDenied
+	}
+	if target.Role == role {
+		return nil
+	}
+
+	if err := s.repo.SetMemberRole(ctx, chatID, userID, role); err != nil {
+		return err
+	}
+	s.forgetMembership(ctx, chatID, userID)
+	s.publish(ctx, Event{Type: EventRoleChanged, ChatID: chatID, UserID: actorID, TargetID: userID, Role: role})
+	return nil
+}
+
+// TransferOwnership makes another member the owner of a chat. The previous
+// owner stays on as an admin.
+func (s *ChatService) TransferOwnership(ctx context.Context, chatID, ownerID, newOwnerID uuid.UUID) error {
+	role, err := s.access.Role(ctx, chatID, ownerID)
+	if err != nil {
+		return err
+	}
+	if role != model.RoleOwner {
+		return ErrPermissionDenied
+	}
+	if newOwnerID == ownerID {
+		return nil
+	}
+
+	target, err := s.repo.GetChatMember(ctx, chatID, newOwnerID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return ErrMemberNotFound
+	}
+
+	err = s.repo.TransferOwnership(ctx, chatID, ownerID, newOwnerID)
+	if errors.Is(err, repository.ErrMembershipChanged) {
+		// Someone changed the memberships meanwhile
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.forgetMembership(ctx, chatID, ownerID, newOwnerID)
+	s.publish(ctx, Event{Type: EventRoleChanged, ChatID: chatID, UserID: ownerID, TargetID: ownerID, Role: model.RoleAdmin})
+	s.publish(ctx, Event{Type: EventRoleChanged, ChatID: chatID, UserID: ownerID, TargetID: newOwnerID, Role: model.RoleOwner})
+	return nil
+}
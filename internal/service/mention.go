@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// channelMention mentions every member of the chat
+	channelMention = "channel"
+	// maxMentionsPerMessage bounds how many names one message resolves
+	maxMentionsPerMessage = 20
+	// defaultMentionLimit is the inbox page size when none is given
+	defaultMentionLimit = 50
+)
+
+// mentionPattern matches @name where name is a username. Usernames may be
+// e-mail addresses, so a second @ and dots are allowed inside the name.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(\w[\w.+-]*(?:@\w[\w-]*(?:\.[\w-]+)+)?)`)
+
+// UserLookup finds users by name
+type UserLookup interface {
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+}
+
+// SetUserLookup sets how @name mentions are resolved; without one only
+// @channel mentions are recognised
+func (s *MessageService) SetUserLookup(users UserLookup) {
+	s.users = users
+}
+
+// parseMentions returns the distinct names mentioned in a text and whether
+// it mentions the whole chat
+func parseMentions(text string) ([]string, bool) {
+	var names []string
+	channel := false
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// A trailing dot ends the sentence rather than the name
+		name := strings.TrimRight(match[1], ".")
+		if name == channelMention {
+			channel = true
+			continue
+		}
+		if seen[name] || len(names) == maxMentionsPerMessage {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, channel
+}
+
+// resolveMentions records the members a new message mentions and returns
+// whom to notify. Names that are not members of the chat are ignored, as
+// are mentions of the sender.
+func (s *MessageService) resolveMentions(ctx context.Context, message *model.Message) ([]uuid.UUID, error) {
+	names, channel := parseMentions(message.Text)
+	if len(names) == 0 && !channel {
+		return nil, nil
+	}
+
+	memberIDs, err := s.repo.GetChatMemberIDs(ctx, message.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat members: %w", err)
+	}
+	members := make(map[uuid.UUID]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		members[id] = true
+	}
+	delete(members, message.SenderID)
+
+	var recipients []uuid.UUID
+	notified := make(map[uuid.UUID]bool)
+	if s.users != nil {
+		for _, name := range names {
+			user, err := s.users.GetByUsername(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve mention @%s: %w", name, err)
+			}
+			if user == nil || !members[user.ID] || notified[user.ID] {
+				continue
+			}
+			notified[user.ID] = true
+			recipients = append(recipients, user.ID)
+			message.Mentions = append(message.Mentions, model.MessageMention{
+				MessageID: message.ID,
+				UserID:    user.ID,
+				Username:  user.Username,
+			})
+		}
+	}
+
+	if channel {
+		message.MentionsChannel = true
+		for _, id := range memberIDs {
+			if members[id] && !notified[id] {
+				notified[id] = true
+				recipients = append(recipients, id)
+			}
+		}
+	}
+	return recipients, nil
+}
+
+// GetMentions returns a page of the messages mentioning the user, newest
+// first, with whether each is unread
+func (s *MessageService) GetMentions(ctx context.Context, userIDStr string, query model.MentionQuery) ([]*model.InboxMention, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultMentionLimit
+	}
+	return s.repo.GetMentions(ctx, userID, query)
+}
+
+// CountUnreadMentions returns how many mentions of the user are unread.
+// Reading a chat up to a message also reads the mentions before it.
+func (s *MessageService) CountUnreadMentions(ctx context.Context, userIDStr string) (int64, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user ID: %w", err)
+	}
+	return s.repo.CountUnreadMentions(ctx, userID)
+}
+
+// notifyMentions tells the mentioned members about a new message
+func (s *MessageService) notifyMentions(ctx context.Context, message *model.Message, recipients []uuid.UUID) {
+	if len(recipients) == 0 {
+		return
+	}
+	s.publish(ctx, Event{Type: EventMention, ChatID: message.ChatID, Message: message, UserIDs: recipients})
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"rtcs/internal/model"
+	"rtcs/internal/storage"
 
 	"github.com/google/uuid"
 )
@@ -21,39 +24,97 @@ type Message struct {
 
 type MessageRepository interface {
 	SaveMessage(ctx context.Context, message *model.Message) error
-	GetMessages(ctx context.Context, chatID uuid.UUID, limit int) ([]*model.Message, error)
+	GetMessagePage(ctx context.Context, chatID uuid.UUID, query model.HistoryQuery) ([]*model.Message, error)
+	GetMessagesAfter(ctx context.Context, chatID uuid.UUID, afterSeq int64, limit int) ([]*model.Message, error)
 	GetMessage(ctx context.Context, messageID uuid.UUID) (*model.Message, error)
-	DeleteMessage(ctx context.Context, messageID uuid.UUID) error
-	CreateChatIfNotExists(ctx context.Context, chat *model.Chat) error
-	AddUserToChat(ctx context.Context, chatID, userID uuid.UUID) error
+	GetReplies(ctx context.Context, parentID uuid.UUID, afterSeq int64, limit int) ([]*model.Message, error)
+	GetThreadParticipants(ctx context.Context, parentID uuid.UUID) ([]uuid.UUID, error)
+	AddReaction(ctx context.Context, reaction *model.MessageReaction) (bool, error)
+	RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) (bool, error)
+	GetReactionSummaries(ctx context.Context, messageIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID][]model.ReactionSummary, error)
+	SearchMessages(ctx context.Context, userID uuid.UUID, query model.SearchQuery) ([]*model.SearchResult, error)
+	GetAttachments(ctx context.Context, attachmentIDs []uuid.UUID) ([]*model.Attachment, error)
+	GetChatMemberIDs(ctx context.Context, chatID uuid.UUID) ([]uuid.UUID, error)
+	GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error)
+	GetMentions(ctx context.Context, userID uuid.UUID, query model.MentionQuery) ([]*model.InboxMention, error)
+	CountUnreadMentions(ctx context.Context, userID uuid.UUID) (int64, error)
+	UpdateMessageText(ctx context.Context, messageID, editorID uuid.UUID, text string, editedAt time.Time) (*model.Message, error)
+	TombstoneMessage(ctx context.Context, messageID, deletedBy uuid.UUID, reason string, deletedAt time.Time) (*model.Message, error)
+	GetChat(ctx context.Context, chatID uuid.UUID) (*model.Chat, error)
 }
 
 type MessageCache interface {
 	SetMessage(ctx context.Context, message *model.Message) error
 	GetMessage(ctx context.Context, messageID string) (*model.Message, error)
 	DeleteMessage(ctx context.Context, messageID string) error
-	SetChatMessages(ctx context.Context, chatID string, messages []*model.Message) error
-	GetChatMessages(ctx context.Context, chatID string) ([]*model.Message, error)
+	SetChatMessages(ctx context.Context, chatID, page string, messages []*model.Message) error
+	GetChatMessages(ctx context.Context, chatID, page string) ([]*model.Message, error)
+	DeleteChatMessages(ctx context.Context, chatID string) error
+	DeleteChatSummary(ctx context.Context, chatID string) error
+	DeleteUnreadCounts(ctx context.Context, chatID string) error
 }
 
 // MessageService defines the interface for message operations
 type MessageService struct {
-	repo  MessageRepository
-	cache MessageCache
+	repo   MessageRepository
+	cache  MessageCache
+	events EventPublisher
+	blobs  storage.BlobStore
+	users  UserLookup
+	access *ChatAccess
 }
 
 // NewMessageService creates a new message service
 func NewMessageService(repo MessageRepository, cache MessageCache) *MessageService {
 	return &MessageService{
-		repo:  repo,
-		cache: cache,
+		repo:   repo,
+		cache:  cache,
+		access: NewChatAccess(repo, nil),
 	}
 }
 
-// SendMessage creates a new message
+// SetChatAccess sets the membership checks for sending and reading
+// messages, so that they can share a cache with the chat service
+func (s *MessageService) SetChatAccess(access *ChatAccess) {
+	s.access = access
+}
+
+// SetEventPublisher sets where message events are published after they are persisted
+func (s *MessageService) SetEventPublisher(events EventPublisher) {
+	s.events = events
+}
+
+// SetBlobStore sets where attachments are stored, so that deleting a
+// message also removes its files
+func (s *MessageService) SetBlobStore(blobs storage.BlobStore) {
+	s.blobs = blobs
+}
+
+// publish emits an event if a publisher is configured
+func (s *MessageService) publish(ctx context.Context, event Event) {
+	if s.events != nil {
+		s.events.Publish(ctx, event)
+	}
+}
+
+// SendMessage creates a new top-level message
 func (s *MessageService) SendMessage(ctx context.Context, chatIDStr, senderIDStr, text string) (*model.Message, error) {
+	return s.SendReply(ctx, chatIDStr, senderIDStr, "", text)
+}
+
+// SendReply creates a message in the thread of parentIDStr, or a top-level
+// message if parentIDStr is empty. Replies to a reply join the thread of its
+// root, so threads are one level deep.
+func (s *MessageService) SendReply(ctx context.Context, chatIDStr, senderIDStr, parentIDStr, text string) (*model.Message, error) {
+	return s.SendWithAttachments(ctx, chatIDStr, senderIDStr, parentIDStr, text, nil)
+}
+
+// SendWithAttachments creates a message like SendReply that also carries
+// files the sender uploaded to the chat beforehand. The text may be empty if
+// there are attachments.
+func (s *MessageService) SendWithAttachments(ctx context.Context, chatIDStr, senderIDStr, parentIDStr, text string, attachmentIDs []string) (*model.Message, error) {
 	// Validate input
-	if text == "" {
+	if text == "" && len(attachmentIDs) == 0 {
 		return nil, fmt.Errorf("message text cannot be empty")
 	}
 	if chatIDStr == "" {
@@ -69,26 +130,44 @@ func (s *MessageService) SendMessage(ctx context.Context, chatIDStr, senderIDStr
 		return nil, fmt.Errorf("invalid sender ID: %w", err)
 	}
 
-	// Create a new chat if it doesn't exist
-	chat := &model.Chat{
-		ID:   chatID,
-		Name: fmt.Sprintf("Chat %s", chatID.String()),
+	// Chats are created explicitly, direct chats through OpenDirectChat
+	chat, err := s.repo.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.repo.CreateChatIfNotExists(ctx, chat); err != nil {
-		return nil, fmt.Errorf("failed to create chat: %w", err)
+	if chat == nil {
+		return nil, ErrChatNotFound
+	}
+	if err := s.access.Require(ctx, chatID, senderID, ActionSendMessages); err != nil {
+		return nil, err
 	}
 
-	// Add user to chat if not already a member
-	if err := s.repo.AddUserToChat(ctx, chatID, senderID); err != nil {
-		return nil, fmt.Errorf("failed to add user to chat: %w", err)
+	var parent *model.Message
+	if parentIDStr != "" {
+		if parent, err = s.threadRoot(ctx, chatID, parentIDStr); err != nil {
+			return nil, err
+		}
+	}
+
+	attachments, err := s.sendableAttachments(ctx, chatID, senderID, attachmentIDs)
+	if err != nil {
+		return nil, err
 	}
 
 	message := &model.Message{
-		ID:        uuid.New(),
-		ChatID:    chatID,
-		SenderID:  senderID,
-		Text:      text,
-		CreatedAt: time.Now(),
+		ID:          uuid.New(),
+		ChatID:      chatID,
+		SenderID:    senderID,
+		Text:        text,
+		CreatedAt:   time.Now(),
+		Attachments: attachments,
+	}
+	if parent != nil {
+		message.ParentID = &parent.ID
+	}
+	mentioned, err := s.resolveMentions(ctx, message)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := s.repo.SaveMessage(ctx, message); err != nil {
@@ -100,29 +179,48 @@ func (s *MessageService) SendMessage(ctx context.Context, chatIDStr, senderIDStr
 		// TODO: Add proper logging
 	}
 
+	// The cached history and summary no longer include the newest message
+	s.invalidateChat(ctx, chatIDStr)
+
+	s.publish(ctx, Event{Type: EventMessageCreated, ChatID: chatID, Message: message})
+
+	if parent != nil {
+		s.notifyThread(ctx, parent, message)
+	}
+	s.notifyMentions(ctx, message, mentioned)
+
 	return message, nil
 }
 
-// GetChatHistory retrieves messages for a chat
-func (s *MessageService) GetChatHistory(ctx context.Context, chatIDStr string, limit int) ([]*model.Message, error) {
+// GetChatHistory retrieves the newest messages of a chat for one of its
+// members
+func (s *MessageService) GetChatHistory(ctx context.Context, chatIDStr, userIDStr string, limit int) ([]*model.Message, error) {
+	return s.GetChatHistoryForUser(ctx, chatIDStr, userIDStr, model.HistoryQuery{Limit: limit})
+}
+
+// GetChatHistoryPage retrieves a page of a chat's messages, newest first.
+// It does not check who is reading; requests made for a user go through
+// GetChatHistoryForUser.
+func (s *MessageService) GetChatHistoryPage(ctx context.Context, chatIDStr string, query model.HistoryQuery) ([]*model.Message, error) {
 	chatID, err := uuid.Parse(chatIDStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid chat ID: %w", err)
 	}
+	page := query.Key()
 
 	// Try to get from cache first
-	if messages, err := s.cache.GetChatMessages(ctx, chatIDStr); err == nil && messages != nil {
+	if messages, err := s.cache.GetChatMessages(ctx, chatIDStr, page); err == nil && messages != nil {
 		return messages, nil
 	}
 
 	// If not in cache, get from database
-	messages, err := s.repo.GetMessages(ctx, chatID, limit)
+	messages, err := s.repo.GetMessagePage(ctx, chatID, query)
 	if err != nil {
 		return nil, err
 	}
 
 	// Update cache
-	if err := s.cache.SetChatMessages(ctx, chatIDStr, messages); err != nil {
+	if err := s.cache.SetChatMessages(ctx, chatIDStr, page, messages); err != nil {
 		// Log error but don't fail the request
 		// TODO: Add proper logging
 	}
@@ -130,8 +228,76 @@ func (s *MessageService) GetChatHistory(ctx context.Context, chatIDStr string, l
 	return messages, nil
 }
 
+// GetMessagesSince retrieves messages of a chat sent after the given
+// sequence number, oldest first
+func (s *MessageService) GetMessagesSince(ctx context.Context, chatIDStr string, afterSeq int64, limit int) ([]*model.Message, error) {
+	chatID, err := uuid.Parse(chatIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chat ID: %w", err)
+	}
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+
+	return s.repo.GetMessagesAfter(ctx, chatID, afterSeq, limit)
+}
+
+// EditMessage replaces the text of a message. Only the sender may edit it;
+// the previous text is kept as a revision.
+func (s *MessageService) EditMessage(ctx context.Context, messageIDStr, userIDStr, text string) (*model.Message, error) {
+	if text == "" {
+		return nil, fmt.Errorf("message text cannot be empty")
+	}
+	messageID, err := uuid.Parse(messageIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message ID: %w", err)
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	message, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.DeletedAt != nil {
+		return nil, ErrMessageNotFound
+	}
+	if message.SenderID != userID {
+		return nil, ErrNotMessageSender
+	}
+	// Former members cannot change what they said
+	if err := s.access.RequireMember(ctx, message.ChatID, userID); err != nil {
+		return nil, err
+	}
+	if message.Text == text {
+		return message, nil
+	}
+
+	message, err = s.repo.UpdateMessageText(ctx, messageID, userID, text, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.SetMessage(ctx, message); err != nil {
+		log.Printf("[WARN] Failed to cache edited message %s: %v", messageIDStr, err)
+	}
+	s.invalidateChat(ctx, message.ChatID.String())
+
+	s.publish(ctx, Event{Type: EventMessageEdited, ChatID: message.ChatID, Message: message})
+
+	return message, nil
+}
+
 // DeleteMessage removes a message
 func (s *MessageService) DeleteMessage(ctx context.Context, messageIDStr string, userIDStr string) error {
+	return s.DeleteMessageWithReason(ctx, messageIDStr, userIDStr, "")
+}
+
+// DeleteMessageWithReason replaces a message with a tombstone recording who
+// deleted it and, optionally, why
+func (s *MessageService) DeleteMessageWithReason(ctx context.Context, messageIDStr, userIDStr, reason string) error {
 	messageID, err := uuid.Parse(messageIDStr)
 	if err != nil {
 		return fmt.Errorf("invalid message ID: %w", err)
@@ -142,26 +308,54 @@ func (s *MessageService) DeleteMessage(ctx context.Context, messageIDStr string,
 	}
 
 	// Get the message first to check ownership
-	message, err := s.repo.GetMessage(ctx, messageID)
+	original, err := s.repo.GetMessage(ctx, messageID)
 	if err != nil {
 		return err
 	}
+	if original == nil || original.DeletedAt != nil {
+		return ErrMessageNotFound
+	}
 
-	// Check if the user owns the message
-	if message.SenderID != userID {
-		return fmt.Errorf("unauthorized: user does not own this message")
+	// Besides the sender, members whose role allows it may delete messages
+	if original.SenderID != userID {
+		err := s.access.Require(ctx, original.ChatID, userID, ActionDeleteMessages)
+		if errors.Is(err, ErrNotChatMember) || errors.Is(err, ErrPermissionDenied) {
+			return ErrNotMessageSender
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	// Delete from database first
-	if err := s.repo.DeleteMessage(ctx, messageID); err != nil {
+	message, err := s.repo.TombstoneMessage(ctx, messageID, userID, reason, time.Now())
+	if err != nil {
 		return err
 	}
+	s.deleteAttachmentBlobs(ctx, original.Attachments)
 
 	// Delete from cache
 	if err := s.cache.DeleteMessage(ctx, messageIDStr); err != nil {
 		// Log error but don't fail the request
 		// TODO: Add proper logging
 	}
+	s.invalidateChat(ctx, message.ChatID.String())
+
+	s.publish(ctx, Event{Type: EventMessageDeleted, ChatID: message.ChatID, Message: message})
 
 	return nil
 }
+
+// invalidateChat drops every cached history page and the summary of a chat
+// after its messages change
+func (s *MessageService) invalidateChat(ctx context.Context, chatID string) {
+	if err := s.cache.DeleteChatMessages(ctx, chatID); err != nil {
+		log.Printf("[WARN] Failed to invalidate history cache of chat %s: %v", chatID, err)
+	}
+	if err := s.cache.DeleteChatSummary(ctx, chatID); err != nil {
+		log.Printf("[WARN] Failed to invalidate summary cache of chat %s: %v", chatID, err)
+	}
+	if err := s.cache.DeleteUnreadCounts(ctx, chatID); err != nil {
+		log.Printf("[WARN] Failed to invalidate unread counts of chat %s: %v", chatID, err)
+	}
+}
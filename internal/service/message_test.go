@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"rtcs/internal/model"
 
@@ -11,28 +15,118 @@ import (
 
 // MockRepository implements the MessageRepository interface for testing
 type MockRepository struct {
-	messages map[string]*model.Message
+	messages    map[string]*model.Message
+	lastSeq     map[uuid.UUID]int64
+	revisions   []*model.MessageRevision
+	reactions   map[model.MessageReaction]bool
+	searches    []model.SearchQuery
+	attachments map[uuid.UUID]*model.Attachment
+	members     map[uuid.UUID][]uuid.UUID
+	// Roles that differ from RoleMember, by user
+	roles map[uuid.UUID]model.ChatRole
 }
 
 func NewMockRepository() *MockRepository {
 	return &MockRepository{
-		messages: make(map[string]*model.Message),
+		messages:    make(map[string]*model.Message),
+		lastSeq:     make(map[uuid.UUID]int64),
+		reactions:   make(map[model.MessageReaction]bool),
+		attachments: make(map[uuid.UUID]*model.Attachment),
+		members:     make(map[uuid.UUID][]uuid.UUID),
+		roles:       make(map[uuid.UUID]model.ChatRole),
 	}
 }
 
 func (m *MockRepository) SaveMessage(ctx context.Context, message *model.Message) error {
+	m.lastSeq[message.ChatID]++
+	message.Seq = m.lastSeq[message.ChatID]
 	m.messages[message.ID.String()] = message
+	for i := range message.Attachments {
+		message.Attachments[i].MessageID = &message.ID
+		m.attachments[message.Attachments[i].ID].MessageID = &message.ID
+	}
+	if message.ParentID != nil {
+		parent := m.messages[message.ParentID.String()]
+		parent.ReplyCount++
+		parent.LastReplyAt = &message.CreatedAt
+	}
 	return nil
 }
 
-func (m *MockRepository) GetMessages(ctx context.Context, chatID uuid.UUID, limit int) ([]*model.Message, error) {
+func (m *MockRepository) GetMessagesAfter(ctx context.Context, chatID uuid.UUID, afterSeq int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	for seq := afterSeq + 1; seq <= m.lastSeq[chatID] && len(messages) < limit; seq++ {
+		for _, msg := range m.messages {
+			if msg.ChatID == chatID && msg.Seq == seq {
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages, nil
+}
+
+func (m *MockRepository) GetMessagePage(ctx context.Context, chatID uuid.UUID, query model.HistoryQuery) ([]*model.Message, error) {
 	var messages []*model.Message
 	for _, msg := range m.messages {
-		if msg.ChatID == chatID {
+		if msg.ChatID == chatID && msg.ParentID == nil {
 			messages = append(messages, msg)
 		}
 	}
-	return messages, nil
+	// Newest first, by the same (created_at, id) order as the database
+	less := func(a, b model.MessageCursor) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	}
+	sort.Slice(messages, func(i, j int) bool { return less(model.CursorAt(messages[j]), model.CursorAt(messages[i])) })
+
+	var page []*model.Message
+	for _, msg := range messages {
+		cursor := model.CursorAt(msg)
+		if query.Before != nil && !less(cursor, *query.Before) {
+			continue
+		}
+		if query.After != nil && !less(*query.After, cursor) {
+			continue
+		}
+		page = append(page, msg)
+	}
+	if len(page) > query.Limit {
+		if query.After != nil {
+			page = page[len(page)-query.Limit:]
+		} else {
+			page = page[:query.Limit]
+		}
+	}
+	return page, nil
+}
+
+func (m *MockRepository) GetReplies(ctx context.Context, parentID uuid.UUID, afterSeq int64, limit int) ([]*model.Message, error) {
+	var replies []*model.Message
+	for _, msg := range m.messages {
+		if msg.ParentID != nil && *msg.ParentID == parentID && msg.Seq > afterSeq {
+			replies = append(replies, msg)
+		}
+	}
+	sort.Slice(replies, func(i, j int) bool { return replies[i].Seq < replies[j].Seq })
+	if len(replies) > limit {
+		replies = replies[:limit]
+	}
+	return replies, nil
+}
+
+func (m *MockRepository) GetThreadParticipants(ctx context.Context, parentID uuid.UUID) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool)
+	var userIDs []uuid.UUID
+	for _, msg := range m.messages {
+		inThread := msg.ID == parentID || (msg.ParentID != nil && *msg.ParentID == parentID)
+		if inThread && !seen[msg.SenderID] {
+			seen[msg.SenderID] = true
+			userIDs = append(userIDs, msg.SenderID)
+		}
+	}
+	return userIDs, nil
 }
 
 func (m *MockRepository) GetMessage(ctx context.Context, messageID uuid.UUID) (*model.Message, error) {
@@ -42,57 +136,207 @@ func (m *MockRepository) GetMessage(ctx context.Context, messageID uuid.UUID) (*
 	return nil, nil
 }
 
-func (m *MockRepository) DeleteMessage(ctx context.Context, messageID uuid.UUID) error {
-	delete(m.messages, messageID.String())
-	return nil
+func (m *MockRepository) UpdateMessageText(ctx context.Context, messageID, editorID uuid.UUID, text string, editedAt time.Time) (*model.Message, error) {
+	msg := m.messages[messageID.String()]
+	m.revisions = append(m.revisions, &model.MessageRevision{MessageID: messageID, Text: msg.Text, EditedBy: editorID, CreatedAt: editedAt})
+	msg.Text = text
+	msg.EditedAt = &editedAt
+	return msg, nil
 }
 
-func (m *MockRepository) CreateChatIfNotExists(ctx context.Context, chat *model.Chat) error {
-	return nil
+func (m *MockRepository) TombstoneMessage(ctx context.Context, messageID, deletedBy uuid.UUID, reason string, deletedAt time.Time) (*model.Message, error) {
+	tombstone := *m.messages[messageID.String()]
+	tombstone.Text = model.DeletedMessageText
+	tombstone.DeletedAt = &deletedAt
+	tombstone.DeletedBy = &deletedBy
+	tombstone.DeleteReason = reason
+	m.messages[messageID.String()] = &tombstone
+	return &tombstone, nil
+}
+
+func (m *MockRepository) AddReaction(ctx context.Context, reaction *model.MessageReaction) (bool, error) {
+	key := model.MessageReaction{MessageID: reaction.MessageID, UserID: reaction.UserID, Emoji: reaction.Emoji}
+	if m.reactions[key] {
+		return false, nil
+	}
+	m.reactions[key] = true
+	return true, nil
+}
+
+func (m *MockRepository) RemoveReaction(ctx context.Context, messageID, userID uuid.UUID, emoji string) (bool, error) {
+	key := model.MessageReaction{MessageID: messageID, UserID: userID, Emoji: emoji}
+	if !m.reactions[key] {
+		return false, nil
+	}
+	delete(m.reactions, key)
+	return true, nil
+}
+
+func (m *MockRepository) GetReactionSummaries(ctx context.Context, messageIDs []uuid.UUID, viewerID uuid.UUID) (map[uuid.UUID][]model.ReactionSummary, error) {
+	summaries := make(map[uuid.UUID][]model.ReactionSummary)
+	for _, messageID := range messageIDs {
+		for key := range m.reactions {
+			if key.MessageID != messageID {
+				continue
+			}
+			found := false
+			for i := range summaries[messageID] {
+				if summaries[messageID][i].Emoji == key.Emoji {
+					summaries[messageID][i].Count++
+					summaries[messageID][i].ReactedByMe = summaries[messageID][i].ReactedByMe || key.UserID == viewerID
+					found = true
+				}
+			}
+			if !found {
+				summaries[messageID] = append(summaries[messageID], model.ReactionSummary{Emoji: key.Emoji, Count: 1, ReactedByMe: key.UserID == viewerID})
+			}
+		}
+	}
+	return summaries, nil
+}
+
+func (m *MockRepository) SearchMessages(ctx context.Context, userID uuid.UUID, query model.SearchQuery) ([]*model.SearchResult, error) {
+	m.searches = append(m.searches, query)
+	var results []*model.SearchResult
+	for _, msg := range m.messages {
+		if msg.DeletedAt == nil && strings.Contains(msg.Text, query.Text) {
+			results = append(results, &model.SearchResult{Message: msg, Snippet: msg.Text})
+		}
+	}
+	return results, nil
+}
+
+func (m *MockRepository) CreateAttachment(ctx context.Context, attachment *model.Attachment) error {
+	m.attachments[attachment.ID] = attachment
+	return attachment.AfterCreate(nil)
+}
+
+func (m *MockRepository) GetAttachment(ctx context.Context, attachmentID uuid.UUID) (*model.Attachment, error) {
+	return m.attachments[attachmentID], nil
+}
+
+func (m *MockRepository) GetAttachments(ctx context.Context, attachmentIDs []uuid.UUID) ([]*model.Attachment, error) {
+	var attachments []*model.Attachment
+	for _, id := range attachmentIDs {
+		if attachment, ok := m.attachments[id]; ok {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+// GetChat finds the chats that have members
+func (m *MockRepository) GetChat(ctx context.Context, chatID uuid.UUID) (*model.Chat, error) {
+	if len(m.members[chatID]) == 0 {
+		return nil, nil
+	}
+	return &model.Chat{ID: chatID, Visibility: model.VisibilityPublic}, nil
+}
+
+// join makes a user a member of a chat, bringing the chat into existence
+func (m *MockRepository) join(chatID, userID string) {
+	m.AddUserToChat(context.Background(), uuid.MustParse(chatID), uuid.MustParse(userID))
 }
 
 func (m *MockRepository) AddUserToChat(ctx context.Context, chatID, userID uuid.UUID) error {
+	for _, id := range m.members[chatID] {
+		if id == userID {
+			return nil
+		}
+	}
+	m.members[chatID] = append(m.members[chatID], userID)
 	return nil
 }
 
+func (m *MockRepository) GetChatMember(ctx context.Context, chatID, userID uuid.UUID) (*model.ChatUser, error) {
+	for _, id := range m.members[chatID] {
+		if id == userID {
+			role, ok := m.roles[userID]
+			if !ok {
+				role = model.RoleMember
+			}
+			return &model.ChatUser{ChatID: chatID, UserID: userID, Role: role}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MockRepository) GetChatMemberIDs(ctx context.Context, chatID uuid.UUID) ([]uuid.UUID, error) {
+	return m.members[chatID], nil
+}
+
+// GetMentions lists every mention as unread, since the mock keeps no read
+// positions
+func (m *MockRepository) GetMentions(ctx context.Context, userID uuid.UUID, query model.MentionQuery) ([]*model.InboxMention, error) {
+	var mentions []*model.InboxMention
+	for _, msg := range m.messages {
+		if msg.SenderID == userID || msg.DeletedAt != nil {
+			continue
+		}
+		mentioned := msg.MentionsChannel
+		for _, mention := range msg.Mentions {
+			mentioned = mentioned || mention.UserID == userID
+		}
+		if mentioned {
+			mentions = append(mentions, &model.InboxMention{Message: msg, Unread: true})
+		}
+	}
+	sort.Slice(mentions, func(i, j int) bool { return mentions[i].Message.CreatedAt.After(mentions[j].Message.CreatedAt) })
+	if len(mentions) > query.Limit {
+		mentions = mentions[:query.Limit]
+	}
+	return mentions, nil
+}
+
+func (m *MockRepository) CountUnreadMentions(ctx context.Context, userID uuid.UUID) (int64, error) {
+	mentions, err := m.GetMentions(ctx, userID, model.MentionQuery{Limit: len(m.messages)})
+	return int64(len(mentions)), err
+}
+
+// mockUsers resolves usernames for mention tests
+type mockUsers map[string]*model.User
+
+func (u mockUsers) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return u[username], nil
+}
+
 // MockCache implements the MessageCache interface for testing
 type MockCache struct {
-	cache map[string][]*model.Message
+	cache     map[string][]*model.Message
+	messages  map[string]*model.Message
+	summaries map[string]*model.ChatSummary
+	roles     map[string]model.ChatRole
+	unread    map[string]map[string]int64
 }
 
 func NewMockCache() *MockCache {
 	return &MockCache{
-		cache: make(map[string][]*model.Message),
+		cache:     make(map[string][]*model.Message),
+		messages:  make(map[string]*model.Message),
+		summaries: make(map[string]*model.ChatSummary),
+		roles:     make(map[string]model.ChatRole),
+		unread:    make(map[string]map[string]int64),
 	}
 }
 
 func (m *MockCache) SetMessage(ctx context.Context, message *model.Message) error {
-	chatIDStr := message.ChatID.String()
-	if messages, ok := m.cache[chatIDStr]; ok {
-		messages = append(messages, message)
-		m.cache[chatIDStr] = messages
-	} else {
-		m.cache[chatIDStr] = []*model.Message{message}
-	}
+	m.messages[message.ID.String()] = message
 	return nil
 }
 
 func (m *MockCache) GetMessage(ctx context.Context, messageID string) (*model.Message, error) {
-	for _, messages := range m.cache {
-		for _, msg := range messages {
-			if msg.ID.String() == messageID {
-				return msg, nil
-			}
-		}
+	if msg, ok := m.messages[messageID]; ok {
+		return msg, nil
 	}
 	return nil, nil
 }
 
 func (m *MockCache) DeleteMessage(ctx context.Context, messageID string) error {
-	for chatID, messages := range m.cache {
+	delete(m.messages, messageID)
+	for key, messages := range m.cache {
 		for i, msg := range messages {
 			if msg.ID.String() == messageID {
-				m.cache[chatID] = append(messages[:i], messages[i+1:]...)
+				m.cache[key] = append(messages[:i], messages[i+1:]...)
 				return nil
 			}
 		}
@@ -100,18 +344,83 @@ func (m *MockCache) DeleteMessage(ctx context.Context, messageID string) error {
 	return nil
 }
 
-func (m *MockCache) SetChatMessages(ctx context.Context, chatID string, messages []*model.Message) error {
-	m.cache[chatID] = messages
+func (m *MockCache) SetChatMessages(ctx context.Context, chatID, page string, messages []*model.Message) error {
+	m.cache[chatID+"|"+page] = messages
 	return nil
 }
 
-func (m *MockCache) GetChatMessages(ctx context.Context, chatID string) ([]*model.Message, error) {
-	if messages, ok := m.cache[chatID]; ok {
+func (m *MockCache) GetChatMessages(ctx context.Context, chatID, page string) ([]*model.Message, error) {
+	if messages, ok := m.cache[chatID+"|"+page]; ok {
 		return messages, nil
 	}
 	return nil, nil
 }
 
+func (m *MockCache) DeleteChatMessages(ctx context.Context, chatID string) error {
+	for key := range m.cache {
+		if strings.HasPrefix(key, chatID+"|") {
+			delete(m.cache, key)
+		}
+	}
+	return nil
+}
+
+func (m *MockCache) SetChatSummary(ctx context.Context, chatID string, summary *model.ChatSummary) error {
+	m.summaries[chatID] = summary
+	return nil
+}
+
+func (m *MockCache) GetChatSummary(ctx context.Context, chatID string) (*model.ChatSummary, error) {
+	return m.summaries[chatID], nil
+}
+
+func (m *MockCache) DeleteChatSummary(ctx context.Context, chatID string) error {
+	delete(m.summaries, chatID)
+	return nil
+}
+
+func (m *MockCache) SetChatRole(ctx context.Context, chatID, userID string, role model.ChatRole) error {
+	m.roles[chatID+"|"+userID] = role
+	return nil
+}
+
+func (m *MockCache) GetChatRole(ctx context.Context, chatID, userID string) (*model.ChatRole, error) {
+	if role, ok := m.roles[chatID+"|"+userID]; ok {
+		return &role, nil
+	}
+	return nil, nil
+}
+
+func (m *MockCache) DeleteChatRole(ctx context.Context, chatID, userID string) error {
+	delete(m.roles, chatID+"|"+userID)
+	return nil
+}
+
+func (m *MockCache) SetUnreadCount(ctx context.Context, chatID, userID string, count int64) error {
+	if m.unread[chatID] == nil {
+		m.unread[chatID] = make(map[string]int64)
+	}
+	m.unread[chatID][userID] = count
+	return nil
+}
+
+func (m *MockCache) GetUnreadCount(ctx context.Context, chatID, userID string) (*int64, error) {
+	if count, ok := m.unread[chatID][userID]; ok {
+		return &count, nil
+	}
+	return nil, nil
+}
+
+func (m *MockCache) DeleteUnreadCount(ctx context.Context, chatID, userID string) error {
+	delete(m.unread[chatID], userID)
+	return nil
+}
+
+func (m *MockCache) DeleteUnreadCounts(ctx context.Context, chatID string) error {
+	delete(m.unread, chatID)
+	return nil
+}
+
 func TestSendMessage(t *testing.T) {
 	// Create mock dependencies
 	repo := NewMockRepository()
@@ -124,6 +433,7 @@ func TestSendMessage(t *testing.T) {
 	t.Run("Send valid message", func(t *testing.T) {
 		chatID := uuid.New().String()
 		userID := uuid.New().String()
+		repo.join(chatID, userID)
 		message, err := svc.SendMessage(ctx, chatID, userID, "Hello, world!")
 		if err != nil {
 			t.Fatalf("SendMessage failed: %v", err)
@@ -168,7 +478,53 @@ func TestSendMessage(t *testing.T) {
 		}
 	})
 
-	// Test case 2: Send message with empty text
+	// Test case 2: Sending invalidates the cached chat history
+	t.Run("Send message invalidates history and summary caches", func(t *testing.T) {
+		chatID := uuid.New().String()
+		userID := uuid.New().String()
+		repo.join(chatID, userID)
+		cache.SetChatMessages(ctx, chatID, model.HistoryQuery{Limit: 50}.Key(), []*model.Message{})
+		cache.SetChatSummary(ctx, chatID, &model.ChatSummary{})
+
+		message, err := svc.SendMessage(ctx, chatID, userID, "Hello again")
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+
+		history, err := svc.GetChatHistory(ctx, chatID, userID, 50)
+		if err != nil {
+			t.Fatalf("GetChatHistory failed: %v", err)
+		}
+		if len(history) != 1 || history[0].ID != message.ID {
+			t.Errorf("Expected history to contain the sent message, got %v", history)
+		}
+		if summary, _ := cache.GetChatSummary(ctx, chatID); summary != nil {
+			t.Errorf("Expected chat summary to be invalidated, got %v", summary)
+		}
+	})
+
+	t.Run("Non-members can neither send nor read", func(t *testing.T) {
+		chatID := uuid.New().String()
+		repo.join(chatID, uuid.New().String())
+		outsider := uuid.New().String()
+		if _, err := svc.SendMessage(ctx, chatID, outsider, "let me in"); !errors.Is(err, ErrNotChatMember) {
+			t.Errorf("Expected ErrNotChatMember when sending, got %v", err)
+		}
+		if _, err := svc.GetChatHistory(ctx, chatID, outsider, 50); !errors.Is(err, ErrNotChatMember) {
+			t.Errorf("Expected ErrNotChatMember when reading, got %v", err)
+		}
+		if members := repo.members[uuid.MustParse(chatID)]; len(members) != 1 {
+			t.Errorf("Expected the sender not to be added to the chat, got %d members", len(members))
+		}
+	})
+
+	t.Run("Chats are not created by sending", func(t *testing.T) {
+		if _, err := svc.SendMessage(ctx, uuid.New().String(), uuid.New().String(), "hello?"); !errors.Is(err, ErrChatNotFound) {
+			t.Errorf("Expected ErrChatNotFound, got %v", err)
+		}
+	})
+
+	// Test case 3: Send message with empty text
 	t.Run("Send message with empty text", func(t *testing.T) {
 		message, err := svc.SendMessage(ctx, uuid.New().String(), uuid.New().String(), "")
 		if err == nil {
@@ -179,3 +535,565 @@ func TestSendMessage(t *testing.T) {
 		}
 	})
 }
+
+// recordingPublisher captures published events for assertions
+type recordingPublisher struct {
+	events []Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event Event) {
+	p.events = append(p.events, event)
+}
+
+func TestMessageEvents(t *testing.T) {
+	repo := NewMockRepository()
+	cache := NewMockCache()
+	publisher := &recordingPublisher{}
+	svc := NewMessageService(repo, cache)
+	svc.SetEventPublisher(publisher)
+
+	ctx := context.Background()
+	chatID := uuid.New()
+	userID := uuid.New()
+	repo.AddUserToChat(ctx, chatID, userID)
+
+	message, err := svc.SendMessage(ctx, chatID.String(), userID.String(), "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if err := svc.DeleteMessage(ctx, message.ID.String(), userID.String()); err != nil {
+		t.Fatalf("DeleteMessage failed: %v", err)
+	}
+
+	if len(publisher.events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(publisher.events))
+	}
+	if publisher.events[0].Type != EventMessageCreated || publisher.events[0].Message.ID != message.ID {
+		t.Errorf("Expected message_created for %s, got %+v", message.ID, publisher.events[0])
+	}
+	if publisher.events[1].Type != EventMessageDeleted || publisher.events[1].ChatID != chatID {
+		t.Errorf("Expected message_deleted in chat %s, got %+v", chatID, publisher.events[1])
+	}
+}
+
+func TestGetMessagesSince(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewMessageService(repo, NewMockCache())
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	userID := uuid.New().String()
+	repo.join(chatID, userID)
+
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := svc.SendMessage(ctx, chatID, userID, text); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	}
+
+	messages, err := svc.GetMessagesSince(ctx, chatID, 1, 10)
+	if err != nil {
+		t.Fatalf("GetMessagesSince failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages after seq 1, got %d", len(messages))
+	}
+	if messages[0].Seq != 2 || messages[0].Text != "two" || messages[1].Seq != 3 {
+		t.Errorf("Expected seqs 2 and 3 in order, got %d and %d", messages[0].Seq, messages[1].Seq)
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	repo := NewMockRepository()
+	cache := NewMockCache()
+	publisher := &recordingPublisher{}
+	svc := NewMessageService(repo, cache)
+	svc.SetEventPublisher(publisher)
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	senderID := uuid.New().String()
+	repo.join(chatID, senderID)
+
+	message, err := svc.SendMessage(ctx, chatID, senderID, "original")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	cache.SetChatMessages(ctx, chatID, model.HistoryQuery{Limit: 50}.Key(), []*model.Message{message})
+	publisher.events = nil
+
+	t.Run("Only the sender can edit", func(t *testing.T) {
+		_, err := svc.EditMessage(ctx, message.ID.String(), uuid.New().String(), "hijacked")
+		if !errors.Is(err, ErrNotMessageSender) {
+			t.Errorf("Expected ErrNotMessageSender, got %v", err)
+		}
+	})
+
+	t.Run("Unknown message", func(t *testing.T) {
+		_, err := svc.EditMessage(ctx, uuid.New().String(), senderID, "text")
+		if !errors.Is(err, ErrMessageNotFound) {
+			t.Errorf("Expected ErrMessageNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Edit keeps a revision", func(t *testing.T) {
+		edited, err := svc.EditMessage(ctx, message.ID.String(), senderID, "corrected")
+		if err != nil {
+			t.Fatalf("EditMessage failed: %v", err)
+		}
+		if edited.Text != "corrected" || edited.EditedAt == nil {
+			t.Errorf("Expected edited message, got %+v", edited)
+		}
+		if len(repo.revisions) != 1 || repo.revisions[0].Text != "original" {
+			t.Errorf("Expected the original text as revision, got %v", repo.revisions)
+		}
+		if history, _ := cache.GetChatMessages(ctx, chatID, model.HistoryQuery{Limit: 50}.Key()); history != nil {
+			t.Errorf("Expected history cache to be invalidated, got %v", history)
+		}
+		if len(publisher.events) != 1 || publisher.events[0].Type != EventMessageEdited {
+			t.Errorf("Expected a message_edited event, got %v", publisher.events)
+		}
+	})
+}
+
+func TestDeleteMessageLeavesTombstone(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewMessageService(repo, NewMockCache())
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	senderID := uuid.New().String()
+	repo.join(chatID, senderID)
+
+	message, err := svc.SendMessage(ctx, chatID, senderID, "secret")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if err := svc.DeleteMessage(ctx, message.ID.String(), uuid.New().String()); !errors.Is(err, ErrNotMessageSender) {
+		t.Errorf("Expected ErrNotMessageSender, got %v", err)
+	}
+	if err := svc.DeleteMessageWithReason(ctx, message.ID.String(), senderID, "posted by mistake"); err != nil {
+		t.Fatalf("DeleteMessageWithReason failed: %v", err)
+	}
+
+	history, err := svc.GetChatHistory(ctx, chatID, senderID, 50)
+	if err != nil {
+		t.Fatalf("GetChatHistory failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected the tombstone to stay in history, got %d messages", len(history))
+	}
+	tombstone := history[0]
+	if tombstone.Text != model.DeletedMessageText || tombstone.DeletedAt == nil {
+		t.Errorf("Expected a tombstone, got %+v", tombstone)
+	}
+	if tombstone.DeletedBy == nil || tombstone.DeletedBy.String() != senderID || tombstone.DeleteReason != "posted by mistake" {
+		t.Errorf("Expected tombstone to record the deletion, got %+v", tombstone)
+	}
+
+	// Tombstones can be neither deleted again nor edited
+	if err := svc.DeleteMessage(ctx, message.ID.String(), senderID); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+	if _, err := svc.EditMessage(ctx, message.ID.String(), senderID, "revived"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestThreadedReplies(t *testing.T) {
+	repo := NewMockRepository()
+	publisher := &recordingPublisher{}
+	svc := NewMessageService(repo, NewMockCache())
+	svc.SetEventPublisher(publisher)
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	author := uuid.New().String()
+	replier := uuid.New().String()
+	repo.join(chatID, author)
+	repo.join(chatID, replier)
+
+	root, err := svc.SendMessage(ctx, chatID, author, "root")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	first, err := svc.SendReply(ctx, chatID, replier, root.ID.String(), "first")
+	if err != nil {
+		t.Fatalf("SendReply failed: %v", err)
+	}
+	// Replying to a reply stays in the root's thread
+	second, err := svc.SendReply(ctx, chatID, author, first.ID.String(), "second")
+	if err != nil {
+		t.Fatalf("SendReply failed: %v", err)
+	}
+	if second.ParentID == nil || *second.ParentID != root.ID {
+		t.Errorf("Expected reply to join thread %s, got parent %v", root.ID, second.ParentID)
+	}
+
+	otherChatID := uuid.New().String()
+	repo.join(otherChatID, replier)
+	if _, err := svc.SendReply(ctx, otherChatID, replier, root.ID.String(), "elsewhere"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound for a parent in another chat, got %v", err)
+	}
+
+	history, err := svc.GetChatHistory(ctx, chatID, author, 50)
+	if err != nil {
+		t.Fatalf("GetChatHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].ID != root.ID {
+		t.Fatalf("Expected only the thread root in history, got %v", history)
+	}
+	if history[0].ReplyCount != 2 || history[0].LastReplyAt == nil || !history[0].LastReplyAt.Equal(second.CreatedAt) {
+		t.Errorf("Expected 2 replies ending at %v, got %d ending at %v", second.CreatedAt, history[0].ReplyCount, history[0].LastReplyAt)
+	}
+
+	parent, replies, err := svc.GetThread(ctx, root.ID.String(), replier, first.Seq, 50)
+	if err != nil {
+		t.Fatalf("GetThread failed: %v", err)
+	}
+	if parent.ID != root.ID || len(replies) != 1 || replies[0].ID != second.ID {
+		t.Errorf("Expected the replies after %d, got %v", first.Seq, replies)
+	}
+	if _, _, err := svc.GetThread(ctx, root.ID.String(), uuid.New().String(), 0, 50); !errors.Is(err, ErrNotChatMember) {
+		t.Errorf("Expected ErrNotChatMember for an outsider reading the thread, got %v", err)
+	}
+
+	// Each reply notifies the other participants of the thread
+	var notified [][]uuid.UUID
+	for _, event := range publisher.events {
+		if event.Type == EventThreadReply {
+			notified = append(notified, event.UserIDs)
+		}
+	}
+	if len(notified) != 2 {
+		t.Fatalf("Expected 2 thread_reply events, got %d", len(notified))
+	}
+	if len(notified[0]) != 1 || notified[0][0].String() != author {
+		t.Errorf("Expected the author to be notified of the first reply, got %v", notified[0])
+	}
+	if len(notified[1]) != 1 || notified[1][0].String() != replier {
+		t.Errorf("Expected the replier to be notified of the second reply, got %v", notified[1])
+	}
+}
+
+func TestReactions(t *testing.T) {
+	repo := NewMockRepository()
+	publisher := &recordingPublisher{}
+	svc := NewMessageService(repo, NewMockCache())
+	svc.SetEventPublisher(publisher)
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	alice := uuid.New().String()
+	bob := uuid.New().String()
+	repo.join(chatID, alice)
+	repo.join(chatID, bob)
+
+	message, err := svc.SendMessage(ctx, chatID, alice, "Hello")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	for _, userID := range []string{alice, bob, bob} {
+		if err := svc.AddReaction(ctx, message.ID.String(), userID, "👍"); err != nil {
+			t.Fatalf("AddReaction failed: %v", err)
+		}
+	}
+
+	outsider := uuid.New().String()
+	if err := svc.AddReaction(ctx, message.ID.String(), outsider, "👍"); !errors.Is(err, ErrNotChatMember) {
+		t.Errorf("Expected ErrNotChatMember for an outsider's reaction, got %v", err)
+	}
+
+	// Repeating a reaction does not publish a second event
+	added := 0
+	for _, event := range publisher.events {
+		if event.Type == EventReactionAdded {
+			added++
+		}
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 reaction_added events, got %d", added)
+	}
+
+	history, err := svc.GetChatHistoryForUser(ctx, chatID, bob, model.HistoryQuery{Limit: 50})
+	if err != nil {
+		t.Fatalf("GetChatHistoryForUser failed: %v", err)
+	}
+	if len(history) != 1 || len(history[0].Reactions) != 1 {
+		t.Fatalf("Expected one message with one reaction, got %v", history)
+	}
+	if r := history[0].Reactions[0]; r.Emoji != "👍" || r.Count != 2 || !r.ReactedByMe {
+		t.Errorf("Expected 2 thumbs up including bob's, got %+v", r)
+	}
+
+	if err := svc.RemoveReaction(ctx, message.ID.String(), bob, "👍"); err != nil {
+		t.Fatalf("RemoveReaction failed: %v", err)
+	}
+	history, err = svc.GetChatHistoryForUser(ctx, chatID, bob, model.HistoryQuery{Limit: 50})
+	if err != nil {
+		t.Fatalf("GetChatHistoryForUser failed: %v", err)
+	}
+	if r := history[0].Reactions[0]; r.Count != 1 || r.ReactedByMe {
+		t.Errorf("Expected only alice's reaction after removal, got %+v", r)
+	}
+
+	for _, emoji := range []string{"", "a b", "\xff"} {
+		if err := svc.AddReaction(ctx, message.ID.String(), bob, emoji); !errors.Is(err, ErrInvalidReaction) {
+			t.Errorf("Expected ErrInvalidReaction for %q, got %v", emoji, err)
+		}
+	}
+}
+
+func TestChatHistoryPages(t *testing.T) {
+	repo := NewMockRepository()
+	cache := NewMockCache()
+	svc := NewMessageService(repo, cache)
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	userID := uuid.New().String()
+	repo.join(chatID, userID)
+
+	// Messages created in the same microsecond are ordered by ID
+	createdAt := time.Now().Truncate(time.Microsecond)
+	var sent []*model.Message
+	for i := 0; i < 5; i++ {
+		message, err := svc.SendMessage(ctx, chatID, userID, "message")
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+		message.CreatedAt = createdAt.Add(time.Duration(i/2) * time.Second)
+		sent = append(sent, message)
+	}
+
+	var seen []*model.Message
+	query := model.HistoryQuery{Limit: 2}
+	for {
+		page, err := svc.GetChatHistoryPage(ctx, chatID, query)
+		if err != nil {
+			t.Fatalf("GetChatHistoryPage failed: %v", err)
+		}
+		seen = append(seen, page...)
+		if len(page) < query.Limit {
+			break
+		}
+		// The cursor round-trips through its opaque form
+		cursor, err := model.ParseMessageCursor(model.CursorAt(page[len(page)-1]).Encode())
+		if err != nil {
+			t.Fatalf("ParseMessageCursor failed: %v", err)
+		}
+		query.Before = &cursor
+	}
+	if len(seen) != len(sent) {
+		t.Fatalf("Expected every message once, got %d of %d", len(seen), len(sent))
+	}
+	ids := make(map[uuid.UUID]bool)
+	for i, message := range seen {
+		ids[message.ID] = true
+		if i > 0 && message.CreatedAt.After(seen[i-1].CreatedAt) {
+			t.Errorf("Expected newest first, got %v after %v", message.CreatedAt, seen[i-1].CreatedAt)
+		}
+	}
+	if len(ids) != len(sent) {
+		t.Errorf("Expected %d distinct messages, got %d", len(sent), len(ids))
+	}
+
+	// Paging forward from the oldest message returns the next newer ones
+	oldest := model.CursorAt(seen[len(seen)-1])
+	newer, err := svc.GetChatHistoryPage(ctx, chatID, model.HistoryQuery{After: &oldest, Limit: 2})
+	if err != nil {
+		t.Fatalf("GetChatHistoryPage failed: %v", err)
+	}
+	if len(newer) != 2 || newer[0].ID != seen[len(seen)-3].ID || newer[1].ID != seen[len(seen)-2].ID {
+		t.Errorf("Expected the two messages after the oldest, got %v", newer)
+	}
+
+	// Each page is cached under its own key and a new message drops them all
+	if len(cache.cache) < 3 {
+		t.Errorf("Expected a cache entry per page, got %d", len(cache.cache))
+	}
+	if _, err := svc.SendMessage(ctx, chatID, userID, "newest"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if len(cache.cache) != 0 {
+		t.Errorf("Expected every cached page to be invalidated, got %d", len(cache.cache))
+	}
+
+	if _, err := model.ParseMessageCursor("not-a-cursor"); !errors.Is(err, model.ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewMessageService(repo, NewMockCache())
+
+	ctx := context.Background()
+	chatID := uuid.New().String()
+	userID := uuid.New().String()
+	repo.join(chatID, userID)
+
+	if _, err := svc.SendMessage(ctx, chatID, userID, "lunch at noon"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	results, err := svc.SearchMessages(ctx, userID, model.SearchQuery{Text: "  lunch "})
+	if err != nil {
+		t.Fatalf("SearchMessages failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(results))
+	}
+	if q := repo.searches[0]; q.Text != "lunch" || q.Limit != defaultSearchLimit {
+		t.Errorf("Expected trimmed text and the default limit, got %+v", q)
+	}
+
+	now := time.Now()
+	invalid := []model.SearchQuery{
+		{Text: "   "},
+		{Text: "lunch", From: &now, To: &now},
+	}
+	for _, query := range invalid {
+		if _, err := svc.SearchMessages(ctx, userID, query); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("Expected ErrInvalidSearch for %+v, got %v", query, err)
+		}
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text    string
+		names   []string
+		channel bool
+	}{
+		{"hi @alice and @bob.", []string{"alice", "bob"}, false},
+		{"@channel release at 5", nil, true},
+		{"ping @alice@example.com, @alice again", []string{"alice@example.com", "alice"}, false},
+		{"mail me at bob@example.com", nil, false},
+		{"@alice @alice", []string{"alice"}, false},
+	}
+
+	for _, tt := range tests {
+		names, channel := parseMentions(tt.text)
+		if strings.Join(names, ",") != strings.Join(tt.names, ",") || channel != tt.channel {
+			t.Errorf("parseMentions(%q) = %v, %v; want %v, %v", tt.text, names, channel, tt.names, tt.channel)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	repo := NewMockRepository()
+	publisher := &recordingPublisher{}
+	svc := NewMessageService(repo, NewMockCache())
+	svc.SetEventPublisher(publisher)
+
+	ctx := context.Background()
+	chatID := uuid.New()
+	alice := &model.User{ID: uuid.New(), Username: "alice"}
+	bob := &model.User{ID: uuid.New(), Username: "bob"}
+	outsider := &model.User{ID: uuid.New(), Username: "carol"}
+	svc.SetUserLookup(mockUsers{"alice": alice, "bob": bob, "carol": outsider})
+	for _, user := range []*model.User{alice, bob} {
+		repo.AddUserToChat(ctx, chatID, user.ID)
+	}
+
+	mentionEvents := func() []Event {
+		var events []Event
+		for _, event := range publisher.events {
+			if event.Type == EventMention {
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+
+	message, err := svc.SendMessage(ctx, chatID.String(), alice.ID.String(), "@bob @carol @nobody @alice look")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	// Non-members, unknown names and the sender are not mentioned
+	if len(message.Mentions) != 1 || message.Mentions[0].UserID != bob.ID || message.MentionsChannel {
+		t.Fatalf("Expected only bob mentioned, got %+v", message.Mentions)
+	}
+	events := mentionEvents()
+	if len(events) != 1 || len(events[0].UserIDs) != 1 || events[0].UserIDs[0] != bob.ID {
+		t.Fatalf("Expected one mention event for bob, got %+v", events)
+	}
+
+	t.Run("Channel mentions notify every other member", func(t *testing.T) {
+		dave := uuid.New()
+		repo.AddUserToChat(ctx, chatID, dave)
+		message, err := svc.SendMessage(ctx, chatID.String(), alice.ID.String(), "@channel @bob standup")
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+		if !message.MentionsChannel || len(message.Mentions) != 1 {
+			t.Errorf("Expected a channel mention and bob by name, got %v %+v", message.MentionsChannel, message.Mentions)
+		}
+		events := mentionEvents()
+		recipients := events[len(events)-1].UserIDs
+		if len(recipients) != 2 {
+			t.Errorf("Expected bob and dave notified once each, got %v", recipients)
+		}
+	})
+
+	t.Run("Messages without mentions notify nobody", func(t *testing.T) {
+		before := len(mentionEvents())
+		if _, err := svc.SendMessage(ctx, chatID.String(), bob.ID.String(), "mail alice@example.com"); err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+		if len(mentionEvents()) != before {
+			t.Error("Expected no mention event")
+		}
+	})
+
+	mentions, err := svc.GetMentions(ctx, bob.ID.String(), model.MentionQuery{})
+	if err != nil {
+		t.Fatalf("GetMentions failed: %v", err)
+	}
+	if len(mentions) != 2 || !mentions[0].Unread {
+		t.Errorf("Expected bob's two mentions, got %d", len(mentions))
+	}
+	if count, err := svc.CountUnreadMentions(ctx, bob.ID.String()); err != nil || count != 2 {
+		t.Errorf("CountUnreadMentions = %d, %v; want 2", count, err)
+	}
+}
+
+func TestMessageRoles(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewMessageService(repo, NewMockCache())
+
+	ctx := context.Background()
+	chatID := uuid.New()
+	senderID, adminID, readerID := uuid.New(), uuid.New(), uuid.New()
+	for _, userID := range []uuid.UUID{senderID, adminID, readerID} {
+		repo.AddUserToChat(ctx, chatID, userID)
+	}
+	repo.roles[adminID] = model.RoleAdmin
+	repo.roles[readerID] = model.RoleReadOnly
+
+	if _, err := svc.SendMessage(ctx, chatID.String(), readerID.String(), "hello"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected read-only members to be refused, got %v", err)
+	}
+
+	message, err := svc.SendMessage(ctx, chatID.String(), senderID.String(), "off topic")
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if err := svc.AddReaction(ctx, message.ID.String(), readerID.String(), "👍"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected read-only members not to react, got %v", err)
+	}
+	if err := svc.RemoveReaction(ctx, message.ID.String(), readerID.String(), "👍"); err != nil {
+		t.Errorf("Expected read-only members to withdraw reactions, got %v", err)
+	}
+	if err := svc.DeleteMessage(ctx, message.ID.String(), readerID.String()); !errors.Is(err, ErrNotMessageSender) {
+		t.Errorf("Expected ErrNotMessageSender for a read-only member, got %v", err)
+	}
+	if err := svc.DeleteMessageWithReason(ctx, message.ID.String(), adminID.String(), "off topic"); err != nil {
+		t.Fatalf("Expected admins to delete others' messages, got %v", err)
+	}
+	if deleted := repo.messages[message.ID.String()]; deleted.DeletedAt == nil || *deleted.DeletedBy != adminID {
+		t.Errorf("Expected a tombstone deleted by the admin, got %+v", deleted)
+	}
+}
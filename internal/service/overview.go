@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// previewLength is the maximum number of characters of a message preview
+const previewLength = 100
+
+// ListChatOverviews returns the user's chats with unread counts and their
+// latest activity, most recently active first. Unread counts only include
+// visible top-level messages from others since the member's read position,
+// which starts at the end of the history when they join. Counts are cached
+// per member until a message is sent, edited or deleted in the chat, or the
+// member reads it.
+func (s *ChatService) ListChatOverviews(ctx context.Context, userID uuid.UUID) ([]*model.ChatOverview, error) {
+	memberships, err := s.repo.ListMemberships(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.unreadCounts(ctx, userID, memberships)
+	if err != nil {
+		return nil, err
+	}
+
+	overviews := make([]*model.ChatOverview, 0, len(memberships))
+	for _, membership := range memberships {
+		if membership.Chat == nil {
+			continue
+		}
+
+		summary, err := s.chatSummary(ctx, membership.Chat)
+		if err != nil {
+			return nil, err
+		}
+
+		overviews = append(overviews, &model.ChatOverview{
+			Chat:           membership.Chat,
+			UnreadCount:    unread[membership.ChatID],
+			LastReadSeq:    membership.LastReadSeq,
+			LastMessage:    summary.LastMessage,
+			LastActivityAt: summary.LastActivityAt,
+		})
+	}
+
+	sort.SliceStable(overviews, func(i, j int) bool {
+		return overviews[i].LastActivityAt.After(overviews[j].LastActivityAt)
+	})
+	return overviews, nil
+}
+
+// unreadCounts returns the user's unread count in each chat, counting only
+// the chats missing from the cache
+func (s *ChatService) unreadCounts(ctx context.Context, userID uuid.UUID, memberships []*model.ChatUser) (map[uuid.UUID]int64, error) {
+	userIDStr := userID.String()
+	counts := make(map[uuid.UUID]int64, len(memberships))
+	var misses []uuid.UUID
+	for _, membership := range memberships {
+		if membership.Chat == nil {
+			continue
+		}
+		if s.cache != nil {
+			if count, err := s.cache.GetUnreadCount(ctx, membership.ChatID.String(), userIDStr); err == nil && count != nil {
+				counts[membership.ChatID] = *count
+				continue
+			}
+		}
+		misses = append(misses, membership.ChatID)
+	}
+	if len(misses) == 0 {
+		return counts, nil
+	}
+
+	fresh, err := s.repo.CountUnreadMessages(ctx, userID, misses)
+	if err != nil {
+		return nil, err
+	}
+	for _, chatID := range misses {
+		counts[chatID] = fresh[chatID]
+		if s.cache != nil {
+			if err := s.cache.SetUnreadCount(ctx, chatID.String(), userIDStr, fresh[chatID]); err != nil {
+				log.Printf("[WARN] Failed to cache unread count of chat %s: %v", chatID, err)
+			}
+		}
+	}
+	return counts, nil
+}
+
+// forgetUnreadCount invalidates a member's cached unread count after their
+// read position or membership changes
+func (s *ChatService) forgetUnreadCount(ctx context.Context, chatID, userID uuid.UUID) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.DeleteUnreadCount(ctx, chatID.String(), userID.String()); err != nil {
+		log.Printf("[WARN] Failed to invalidate unread count of %s in chat %s: %v", userID, chatID, err)
+	}
+}
+
+// chatSummary returns the cached summary of a chat, building it on a miss
+func (s *ChatService) chatSummary(ctx context.Context, chat *model.Chat) (*model.ChatSummary, error) {
+	chatIDStr := chat.ID.String()
+	if s.cache != nil {
+		if summary, err := s.cache.GetChatSummary(ctx, chatIDStr); err == nil && summary != nil {
+			return summary, nil
+		}
+	}
+
+	last, err := s.repo.GetLastMessage(ctx, chat.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &model.ChatSummary{LastActivityAt: chat.CreatedAt}
+	if last != nil {
+		summary.LastMessage = previewMessage(last)
+		summary.LastActivityAt = last.CreatedAt
+	}
+
+	if s.cache != nil {
+		if err := s.cache.SetChatSummary(ctx, chatIDStr, summary); err != nil {
+			log.Printf("[WARN] Failed to cache summary of chat %s: %v", chatIDStr, err)
+		}
+	}
+	return summary, nil
+}
+
+// previewMessage shortens a message for chat lists
+func previewMessage(message *model.Message) *model.MessagePreview {
+	text := []rune(message.Text)
+	if len(text) > previewLength {
+		text = append(text[:previewLength], '…')
+	}
+
+	return &model.MessagePreview{
+		ID:        message.ID,
+		SenderID:  message.SenderID,
+		Text:      string(text),
+		Seq:       message.Seq,
+		CreatedAt: message.CreatedAt,
+	}
+}
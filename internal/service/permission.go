@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// ChatAction is something a member may be permitted to do in a chat
+type ChatAction string
+
+// Actions governed by chat roles
+const (
+	ActionSendMessages   ChatAction = "send_messages"
+	ActionReact          ChatAction = "react"
+	ActionRename         ChatAction = "rename"
+	ActionSetVisibility  ChatAction = "set_visibility"
+	ActionInvite         ChatAction = "invite"
+	ActionKick           ChatAction = "kick"
+	ActionPin            ChatAction = "pin"
+	ActionDeleteMessages ChatAction = "delete_messages" // Other members' messages
+	ActionManageRoles    ChatAction = "manage_roles"
+)
+
+// rolePermissions lists what each role may do. Kicking and role changes are
+// further limited to members the actor outranks.
+var rolePermissions = map[model.ChatRole]map[ChatAction]bool{
+	model.RoleOwner: {
+		ActionSendMessages: true, ActionReact: true, ActionRename: true, ActionSetVisibility: true, ActionInvite: true,
+		ActionKick: true, ActionPin: true, ActionDeleteMessages: true, ActionManageRoles: true,
+	},
+	model.RoleAdmin: {
+		ActionSendMessages: true, ActionReact: true, ActionRename: true, ActionSetVisibility: true, ActionInvite: true,
+		ActionKick: true, ActionPin: true, ActionDeleteMessages: true, ActionManageRoles: true,
+	},
+	model.RoleMember: {
+		ActionSendMessages: true, ActionReact: true,
+	},
+	model.RoleReadOnly: {},
+}
+
+// Can reports whether a role permits an action
+func Can(role model.ChatRole, action ChatAction) bool {
+	return rolePermissions[role][action]
+}
+
+// authorize returns the user's membership in the chat if their role permits
+// the action
+func (s *ChatService) authorize(ctx context.Context, chatID, userID uuid.UUID, action ChatAction) (*model.ChatUser, error) {
+	role, err := s.access.Role(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !Can(role, action) {
+		return nil, ErrPermissionDenied
+	}
+	return &model.ChatUser{ChatID: chatID, UserID: userID, Role: role}, nil
+}
+
+// authorizeOver is authorize for actions on another member, who must exist
+// and rank below the actor
+func (s *ChatService) authorizeOver(ctx context.Context, chatID, actorID, targetID uuid.UUID, action ChatAction) (*model.ChatUser, *model.ChatUser, error) {
+	actor, err := s.authorize(ctx, chatID, actorID, action)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := s.repo.GetChatMember(ctx, chatID, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target == nil {
+		return nil, nil, ErrMemberNotFound
+	}
+	if !actor.Role.Outranks(target.Role) {
+		return nil, nil, ErrPermissionDenied
+	}
+	return actor, target, nil
+}
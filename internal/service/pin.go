@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rtcs/internal/model"
+	"rtcs/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// maxPinsPerChat bounds how many messages a chat can have pinned
+const maxPinsPerChat = 50
+
+// PinMessage pins a message of the chat. Pinning a pinned message is a no-op.
+func (s *ChatService) PinMessage(ctx context.Context, chatID, messageID, userID uuid.UUID) error {
+	message, err := s.pinnableMessage(ctx, chatID, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := s.repo.PinMessage(ctx, &model.ChatPin{
+		ChatID:    chatID,
+		MessageID: messageID,
+		PinnedBy:  userID,
+		PinnedAt:  time.Now(),
+	}, maxPinsPerChat)
+	if errors.Is(err, repository.ErrPinLimitReached) {
+		return ErrPinLimitReached
+	}
+	if err != nil {
+		return err
+	}
+	if pinned {
+		s.publish(ctx, Event{Type: EventPinsChanged, ChatID: chatID, UserID: userID, Message: message, Pinned: true})
+	}
+	return nil
+}
+
+// UnpinMessage unpins a message of the chat. Unpinning a message that is not
+// pinned is a no-op.
+func (s *ChatService) UnpinMessage(ctx context.Context, chatID, messageID, userID uuid.UUID) error {
+	if _, err := s.authorize(ctx, chatID, userID, ActionPin); err != nil {
+		return err
+	}
+
+	unpinned, err := s.repo.UnpinMessage(ctx, chatID, messageID)
+	if err != nil {
+		return err
+	}
+	if unpinned {
+		s.publish(ctx, Event{Type: EventPinsChanged, ChatID: chatID, UserID: userID, Message: &model.Message{ID: messageID, ChatID: chatID}})
+	}
+	return nil
+}
+
+// ListPins returns the pinned messages of a chat to one of its members, most
+// recently pinned first
+func (s *ChatService) ListPins(ctx context.Context, chatID, userID uuid.UUID) ([]*model.ChatPin, error) {
+	if err := s.RequireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPins(ctx, chatID)
+}
+
+// pinnableMessage checks that the user may pin the message in the chat
+func (s *ChatService) pinnableMessage(ctx context.Context, chatID, messageID, userID uuid.UUID) (*model.Message, error) {
+	if _, err := s.authorize(ctx, chatID, userID, ActionPin); err != nil {
+		return nil, err
+	}
+
+	message, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.ChatID != chatID || message.DeletedAt != nil {
+		return nil, ErrMessageNotFound
+	}
+	return message, nil
+}
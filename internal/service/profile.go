@@ -3,15 +3,31 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"rtcs/internal/imaging"
 	"rtcs/internal/model"
 	"rtcs/internal/repository"
+	"rtcs/internal/storage"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+// AvatarSizes are the square thumbnails stored for each avatar. The
+// avatar URL names the first; the others replace its "-<size>.jpg" suffix.
+var AvatarSizes = []int{256, 64}
+
+// avatarFile matches the file names of stored avatar thumbnails
+var avatarFile = regexp.MustCompile(`^([0-9a-f-]{36})-[0-9]+\.jpg$`)
+
 // ProfileService handles user profile operations
 type ProfileService struct {
 	userRepo *repository.UserRepository
+	blobs    storage.BlobStore
 }
 
 // NewProfileService creates a new profile service
@@ -21,6 +37,11 @@ func NewProfileService(userRepo *repository.UserRepository) *ProfileService {
 	}
 }
 
+// SetBlobStore sets where uploaded avatars are stored
+func (s *ProfileService) SetBlobStore(blobs storage.BlobStore) {
+	s.blobs = blobs
+}
+
 // GetProfile retrieves a user's profile
 func (s *ProfileService) GetProfile(ctx context.Context, userID uuid.UUID) (*model.UserProfile, error) {
 	user, err := s.userRepo.GetByID(ctx, userID)
@@ -49,6 +70,92 @@ func (s *ProfileService) UpdateProfile(ctx context.Context, userID uuid.UUID, pr
 	return s.userRepo.UpdateProfile(ctx, userID, profile)
 }
 
+// UploadAvatar replaces a user's avatar with thumbnails of an uploaded
+// image and returns the updated profile
+func (s *ProfileService) UploadAvatar(ctx context.Context, userID uuid.UUID, content io.ReadSeeker) (*model.UserProfile, error) {
+	if s.blobs == nil {
+		return nil, errors.New("avatar uploads are not configured")
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	img, err := imaging.Decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	// Every upload gets new keys, so avatar URLs can be cached forever
+	version := uuid.New().String()
+	var stored []string
+	for _, size := range AvatarSizes {
+		key := avatarKey(userID, version, size)
+		if err := putJPEG(ctx, s.blobs, key, imaging.Square(img, size)); err != nil {
+			s.deleteBlobs(ctx, stored)
+			return nil, fmt.Errorf("failed to store avatar: %w", err)
+		}
+		stored = append(stored, key)
+	}
+
+	avatarURL := "/" + avatarKey(userID, version, AvatarSizes[0])
+	if err := s.userRepo.UpdateAvatarURL(ctx, userID, avatarURL); err != nil {
+		s.deleteBlobs(ctx, stored)
+		return nil, err
+	}
+	s.deleteBlobs(ctx, s.avatarKeys(userID, user.AvatarURL))
+
+	user.AvatarURL = avatarURL
+	return user.ToProfile(), nil
+}
+
+// OpenAvatar returns a stored avatar thumbnail; the caller closes it
+func (s *ProfileService) OpenAvatar(ctx context.Context, userID uuid.UUID, file string) (io.ReadCloser, error) {
+	if s.blobs == nil || !avatarFile.MatchString(file) {
+		return nil, ErrAvatarNotFound
+	}
+	content, err := s.blobs.Get(ctx, "avatars/"+userID.String()+"/"+file)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrAvatarNotFound
+	}
+	return content, err
+}
+
+// avatarKeys returns the stored thumbnails behind an avatar URL, or none
+// for URLs set by the client
+func (s *ProfileService) avatarKeys(userID uuid.UUID, avatarURL string) []string {
+	file, ok := strings.CutPrefix(avatarURL, "/avatars/"+userID.String()+"/")
+	if !ok {
+		return nil
+	}
+	match := avatarFile.FindStringSubmatch(file)
+	if match == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		keys = append(keys, avatarKey(userID, match[1], size))
+	}
+	return keys
+}
+
+// deleteBlobs removes replaced avatar files. Failures only leave
+// unreachable files behind, so they are logged.
+func (s *ProfileService) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Printf("[WARN] Failed to delete avatar blob %s: %v", key, err)
+		}
+	}
+}
+
+func avatarKey(userID uuid.UUID, version string, size int) string {
+	return "avatars/" + userID.String() + "/" + version + "-" + strconv.Itoa(size) + ".jpg"
+}
+
 // GetProfiles retrieves profiles for multiple users
 func (s *ProfileService) GetProfiles(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]*model.UserProfile, error) {
 	return s.userRepo.GetProfiles(ctx, userIDs)
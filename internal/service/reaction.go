@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// maxEmojiRunes bounds a reaction; emoji with modifiers and joiners span
+// several code points
+const maxEmojiRunes = 16
+
+// AddReaction records a user's emoji reaction to a message. Reacting twice
+// with the same emoji is a no-op.
+func (s *MessageService) AddReaction(ctx context.Context, messageIDStr, userIDStr, emoji string) error {
+	message, userID, err := s.reactionTarget(ctx, messageIDStr, userIDStr, emoji)
+	if err != nil {
+		return err
+	}
+	if err := s.access.Require(ctx, message.ChatID, userID, ActionReact); err != nil {
+		return err
+	}
+
+	added, err := s.repo.AddReaction(ctx, &model.MessageReaction{
+		MessageID: message.ID,
+		UserID:    userID,
+		Emoji:     emoji,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if added {
+		s.publish(ctx, Event{Type: EventReactionAdded, ChatID: message.ChatID, Message: message, UserID: userID, Emoji: emoji})
+	}
+	return nil
+}
+
+// RemoveReaction withdraws a user's emoji reaction to a message. Members
+// who may no longer react can still withdraw the reactions they made.
+func (s *MessageService) RemoveReaction(ctx context.Context, messageIDStr, userIDStr, emoji string) error {
+	message, userID, err := s.reactionTarget(ctx, messageIDStr, userIDStr, emoji)
+	if err != nil {
+		return err
+	}
+	if err := s.access.RequireMember(ctx, message.ChatID, userID); err != nil {
+		return err
+	}
+
+	removed, err := s.repo.RemoveReaction(ctx, message.ID, userID, emoji)
+	if err != nil {
+		return err
+	}
+	if removed {
+		s.publish(ctx, Event{Type: EventReactionRemoved, ChatID: message.ChatID, Message: message, UserID: userID, Emoji: emoji})
+	}
+	return nil
+}
+
+// GetChatHistoryForUser retrieves a page of a chat's messages with their
+// reactions as seen by the given user, who must be a member of the chat
+func (s *MessageService) GetChatHistoryForUser(ctx context.Context, chatIDStr, userIDStr string, query model.HistoryQuery) ([]*model.Message, error) {
+	chatID, err := uuid.Parse(chatIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chat ID: %w", err)
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	if err := s.access.RequireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	messages, err := s.GetChatHistoryPage(ctx, chatIDStr, query)
+	if err != nil {
+		return nil, err
+	}
+	return s.withReactions(ctx, messages, userID)
+}
+
+// withReactions returns copies of messages with their reaction summaries.
+// The cached history is shared by all users, so the per-user flags are
+// added on the way out rather than stored.
+func (s *MessageService) withReactions(ctx context.Context, messages []*model.Message, viewerID uuid.UUID) ([]*model.Message, error) {
+	messageIDs := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	summaries, err := s.repo.GetReactionSummaries(ctx, messageIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Message, 0, len(messages))
+	for _, message := range messages {
+		withReactions := *message
+		withReactions.Reactions = summaries[message.ID]
+		result = append(result, &withReactions)
+	}
+	return result, nil
+}
+
+// reactionTarget validates a reaction request and loads the message
+func (s *MessageService) reactionTarget(ctx context.Context, messageIDStr, userIDStr, emoji string) (*model.Message, uuid.UUID, error) {
+	if emoji == "" || strings.ContainsAny(emoji, " \t\r\n") || !utf8.ValidString(emoji) || utf8.RuneCountInString(emoji) > maxEmojiRunes {
+		return nil, uuid.Nil, ErrInvalidReaction
+	}
+	messageID, err := uuid.Parse(messageIDStr)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("invalid message ID: %w", err)
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	message, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if message == nil || message.DeletedAt != nil {
+		return nil, uuid.Nil, ErrMessageNotFound
+	}
+	return message, userID, nil
+}
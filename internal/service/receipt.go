@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// MarkDelivered records that a member has received every message up to and
+// including messageID. Positions never move backwards; acknowledging an older
+// message is a no-op.
+func (s *ChatService) MarkDelivered(ctx context.Context, chatID, userID, messageID uuid.UUID) error {
+	message, err := s.receiptMessage(ctx, chatID, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	advanced, err := s.repo.MarkDelivered(ctx, chatID, userID, message.Seq)
+	if err != nil {
+		return err
+	}
+	if advanced {
+		s.publish(ctx, Event{Type: EventMessageDelivered, ChatID: chatID, UserID: userID, Message: message})
+	}
+	return nil
+}
+
+// MarkRead records that a member has read every message up to and including
+// messageID. Reading also advances the delivered position.
+func (s *ChatService) MarkRead(ctx context.Context, chatID, userID, messageID uuid.UUID) error {
+	message, err := s.receiptMessage(ctx, chatID, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	advanced, err := s.repo.MarkRead(ctx, chatID, userID, message.ID, message.Seq)
+	if err != nil {
+		return err
+	}
+	if advanced {
+		s.forgetUnreadCount(ctx, chatID, userID)
+		s.publish(ctx, Event{Type: EventMessageRead, ChatID: chatID, UserID: userID, Message: message})
+	}
+	return nil
+}
+
+// GetReadPositions returns the delivery and read position of every chat member
+func (s *ChatService) GetReadPositions(ctx context.Context, chatID uuid.UUID) ([]*model.ReadPosition, error) {
+	members, err := s.repo.ListChatMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]*model.ReadPosition, 0, len(members))
+	for _, member := range members {
+		positions = append(positions, member.ReadPosition())
+	}
+	return positions, nil
+}
+
+// receiptMessage checks that the user may acknowledge the message in the chat
+func (s *ChatService) receiptMessage(ctx context.Context, chatID, userID, messageID uuid.UUID) (*model.Message, error) {
+	if err := s.RequireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	message, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.ChatID != chatID {
+		return nil, ErrMessageNotFound
+	}
+	return message, nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// defaultSearchLimit is the page size when the query does not set one
+const defaultSearchLimit = 20
+
+// SearchMessages finds messages matching the query in the chats the user
+// belongs to, newest first
+func (s *MessageService) SearchMessages(ctx context.Context, userIDStr string, query model.SearchQuery) ([]*model.SearchResult, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, fmt.Errorf("%w: query text is required", ErrInvalidSearch)
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidSearch)
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	return s.repo.SearchMessages(ctx, userID, query)
+}
@@ -145,6 +145,34 @@ func (s *StatusService) RefreshUserStatus(ctx context.Context, userID string) er
 	return nil
 }
 
+// GetUserStatuses gets the online status of several users at once. Users
+// without a status are offline.
+func (s *StatusService) GetUserStatuses(ctx context.Context, userIDs []string) (map[string]string, error) {
+	statuses := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return statuses, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = userStatusPrefix + userID
+	}
+	values, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Printf("[STATUS ERROR] Failed to get statuses of %d users: %v", len(userIDs), err)
+		return nil, err
+	}
+
+	for i, userID := range userIDs {
+		status, _ := values[i].(string)
+		if status == "" {
+			status = "offline"
+		}
+		statuses[userID] = status
+	}
+	return statuses, nil
+}
+
 // GetAllOnlineUsers gets all currently online users
 func (s *StatusService) GetAllOnlineUsers(ctx context.Context) ([]string, error) {
 	pattern := userStatusPrefix + "*"
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// GetThread retrieves a thread root and its replies with a sequence greater
+// than afterSeq, oldest first, for a member of the thread's chat
+func (s *MessageService) GetThread(ctx context.Context, parentIDStr, userIDStr string, afterSeq int64, limit int) (*model.Message, []*model.Message, error) {
+	parentID, err := uuid.Parse(parentIDStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid message ID: %w", err)
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+
+	parent, err := s.repo.GetMessage(ctx, parentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil || parent.ParentID != nil {
+		return nil, nil, ErrMessageNotFound
+	}
+	if err := s.access.RequireMember(ctx, parent.ChatID, userID); err != nil {
+		return nil, nil, err
+	}
+
+	replies, err := s.repo.GetReplies(ctx, parentID, afterSeq, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent, replies, nil
+}
+
+// threadRoot returns the message a reply to parentIDStr belongs under
+func (s *MessageService) threadRoot(ctx context.Context, chatID uuid.UUID, parentIDStr string) (*model.Message, error) {
+	parentID, err := uuid.Parse(parentIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent ID: %w", err)
+	}
+
+	parent, err := s.repo.GetMessage(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil && parent.ParentID != nil {
+		parent, err = s.repo.GetMessage(ctx, *parent.ParentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if parent == nil || parent.ChatID != chatID || parent.DeletedAt != nil {
+		return nil, ErrMessageNotFound
+	}
+	return parent, nil
+}
+
+// notifyThread tells the thread's followers, its author and everyone who
+// replied, about a new reply
+func (s *MessageService) notifyThread(ctx context.Context, parent, reply *model.Message) {
+	// The root's reply count and last reply time have changed
+	if err := s.cache.DeleteMessage(ctx, parent.ID.String()); err != nil {
+		log.Printf("[WARN] Failed to invalidate cached message %s: %v", parent.ID, err)
+	}
+
+	participants, err := s.repo.GetThreadParticipants(ctx, parent.ID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load followers of thread %s: %v", parent.ID, err)
+		return
+	}
+
+	followers := make([]uuid.UUID, 0, len(participants))
+	for _, userID := range participants {
+		if userID != reply.SenderID {
+			followers = append(followers, userID)
+		}
+	}
+	if len(followers) == 0 {
+		return
+	}
+
+	s.publish(ctx, Event{Type: EventThreadReply, ChatID: reply.ChatID, Message: reply, UserIDs: followers})
+}
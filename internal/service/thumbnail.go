@@ -0,0 +1,28 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+
+	"rtcs/internal/imaging"
+	"rtcs/internal/storage"
+)
+
+// isThumbnailable reports whether previews can be made of a content type
+func isThumbnailable(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// putJPEG encodes a thumbnail and stores it under key
+func putJPEG(ctx context.Context, blobs storage.BlobStore, key string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := imaging.EncodeJPEG(&buf, img); err != nil {
+		return err
+	}
+	return blobs.Put(ctx, key, &buf, int64(buf.Len()), "image/jpeg")
+}
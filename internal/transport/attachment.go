@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"rtcs/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// multipartOverhead allows for the form encoding around an uploaded file
+	multipartOverhead = 1 << 20
+	// uploadMemory is how much of an upload is buffered in memory before
+	// the rest spills to a temporary file
+	uploadMemory = 8 << 20
+)
+
+// AttachmentHandler handles file uploads and downloads
+type AttachmentHandler struct {
+	attachmentService *service.AttachmentService
+}
+
+// NewAttachmentHandler creates a new attachment handler
+func NewAttachmentHandler(attachmentService *service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+	}
+}
+
+// Upload handles a multipart upload of one file in the "file" field
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := h.attachmentService.UploadLimit(r.Context(), chatID, userID)
+	if err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+
+	// Stop reading oversized uploads early instead of buffering them
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "File exceeds the limit of "+strconv.FormatInt(limit, 10)+" bytes", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(r.Context(), chatID, userID, header.Filename, file, header.Size)
+	if err != nil {
+		log.Printf("Error uploading attachment to chat %s: %v", chatID, err)
+		writeAttachmentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(attachment); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// Download streams an attachment to a member of its chat
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := uuid.Parse(mux.Vars(r)["attachmentId"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	attachment, content, err := h.attachmentService.Open(r.Context(), attachmentID, userID)
+	if err != nil {
+		log.Printf("Error opening attachment %s: %v", attachmentID, err)
+		writeAttachmentError(w, err)
+		return
+	}
+	defer content.Close()
+
+	// Only images are shown inline; anything else is downloaded, so
+	// uploaded HTML never runs in the API's origin
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error sending attachment %s: %v", attachmentID, err)
+	}
+}
+
+// Thumbnail streams the JPEG preview of an image attachment
+func (h *AttachmentHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := uuid.Parse(mux.Vars(r)["attachmentId"])
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, content, err := h.attachmentService.OpenThumbnail(r.Context(), attachmentID, userID)
+	if err != nil {
+		log.Printf("Error opening thumbnail of attachment %s: %v", attachmentID, err)
+		writeAttachmentError(w, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error sending thumbnail of attachment %s: %v", attachmentID, err)
+	}
+}
+
+func writeAttachmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotChatMember):
+		http.Error(w, "Not a member of this chat", http.StatusForbidden)
+	case errors.Is(err, service.ErrAttachmentNotFound):
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrInvalidAttachment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to process attachment", http.StatusInternalServerError)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"rtcs/internal/service"
+
+	"github.com/google/uuid"
 )
 
 // AuthHandler handles authentication requests
@@ -33,6 +35,10 @@ type LoginResponse struct {
 	Token string `json:"token"`
 }
 
+type WebSocketTicketResponse struct {
+	Ticket string `json:"ticket"`
+}
+
 // Register handles user registration
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
@@ -75,3 +81,26 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// WebSocketTicket issues a short-lived ticket for authenticating a WebSocket
+// handshake from clients that cannot set the Authorization header
+func (h *AuthHandler) WebSocketTicket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ticket, err := h.authService.GenerateWebSocketTicket(userID.String())
+	if err != nil {
+		http.Error(w, "Failed to issue ticket", http.StatusInternalServerError)
+		return
+	}
+
+	response := WebSocketTicketResponse{Ticket: ticket}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
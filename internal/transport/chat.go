@@ -1,9 +1,13 @@
 package transport
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
+	"rtcs/internal/model"
 	"rtcs/internal/service"
 
 	"github.com/google/uuid"
@@ -19,7 +23,17 @@ func NewChatHandler(service *service.ChatService) *ChatHandler {
 }
 
 type createChatRequest struct {
-	Name string `json:"name"`
+	Name       string               `json:"name"`
+	Visibility model.ChatVisibility `json:"visibility"` // Public when empty
+}
+
+type chatResponse struct {
+	*model.Chat
+	ReadPositions []*model.ReadPosition `json:"read_positions"`
+}
+
+type receiptRequest struct {
+	MessageID string `json:"message_id"`
 }
 
 func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
@@ -36,9 +50,13 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chat, err := h.service.CreateChat(r.Context(), req.Name, userID)
+	if req.Visibility == "" {
+		req.Visibility = model.VisibilityPublic
+	}
+
+	chat, err := h.service.CreateChatWithVisibility(r.Context(), req.Name, userID, req.Visibility)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeChatError(w, err)
 		return
 	}
 
@@ -54,18 +72,27 @@ func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chat, err := h.service.GetChat(r.Context(), chatID)
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chat, err := h.service.GetChatForMember(r.Context(), chatID, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeChatError(w, err)
 		return
 	}
-	if chat == nil {
-		http.Error(w, "Chat not found", http.StatusNotFound)
+
+	positions, err := h.service.GetReadPositions(r.Context(), chatID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chat)
+	json.NewEncoder(w).Encode(chatResponse{Chat: chat, ReadPositions: positions})
 }
 
 func (h *ChatHandler) ListChats(w http.ResponseWriter, r *http.Request) {
@@ -76,7 +103,7 @@ func (h *ChatHandler) ListChats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chats, err := h.service.ListChats(r.Context(), userID)
+	chats, err := h.service.ListChatOverviews(r.Context(), userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -102,7 +129,7 @@ func (h *ChatHandler) JoinChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.JoinChat(r.Context(), chatID, userID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeChatError(w, err)
 		return
 	}
 
@@ -125,9 +152,88 @@ func (h *ChatHandler) LeaveChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.LeaveChat(r.Context(), chatID, userID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeChatError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *ChatHandler) MarkDelivered(w http.ResponseWriter, r *http.Request) {
+	h.handleReceipt(w, r, h.service.MarkDelivered)
+}
+
+func (h *ChatHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	h.handleReceipt(w, r, h.service.MarkRead)
+}
+
+func (h *ChatHandler) handleReceipt(w http.ResponseWriter, r *http.Request, mark func(ctx context.Context, chatID, userID, messageID uuid.UUID) error) {
+	vars := mux.Vars(r)
+	chatID, err := uuid.Parse(vars["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req receiptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	messageID, err := uuid.Parse(req.MessageID)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := mark(r.Context(), chatID, userID, messageID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotChatMember):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, service.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeChatError maps the errors of chat management to responses
+func writeChatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotChatMember), errors.Is(err, service.ErrPermissionDenied),
+		errors.Is(err, service.ErrPrivateChat):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrChatNotFound):
+		http.Error(w, "Chat not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInviteNotFound):
+		http.Error(w, "Invite not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInviteExpired), errors.Is(err, service.ErrInviteUsedUp):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, service.ErrMessageNotFound):
+		http.Error(w, "Message not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrMemberNotFound):
+		http.Error(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrPinLimitReached), errors.Is(err, service.ErrOwnerCannotLeave):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidChatName),
+		errors.Is(err, service.ErrInvalidVisibility), errors.Is(err, service.ErrInvalidInvite),
+		errors.Is(err, service.ErrInvalidDirectChat), errors.Is(err, service.ErrTooManyMembers):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error managing chat: %v", err)
+		http.Error(w, "Failed to update chat", http.StatusInternalServerError)
+	}
+}
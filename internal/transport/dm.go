@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type groupDMRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// OpenDirectChat handles finding or creating the caller's direct chat with
+// another user
+func (h *ChatHandler) OpenDirectChat(w http.ResponseWriter, r *http.Request) {
+	otherID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chat, created, err := h.service.OpenDirectChat(r.Context(), userID, otherID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err := json.NewEncoder(w).Encode(chat); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// CreateGroupDM handles creating a direct chat between the caller and
+// several other users
+func (h *ChatHandler) CreateGroupDM(w http.ResponseWriter, r *http.Request) {
+	var req groupDMRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	otherIDs := make([]uuid.UUID, len(req.UserIDs))
+	for i, id := range req.UserIDs {
+		otherID, err := uuid.Parse(id)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		otherIDs[i] = otherID
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chat, err := h.service.CreateGroupDM(r.Context(), userID, otherIDs)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(chat); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
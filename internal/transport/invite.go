@@ -0,0 +1,138 @@
+package transport
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"rtcs/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type createInviteRequest struct {
+	ExpiresIn int64 `json:"expires_in"` // Seconds; a week when zero
+	MaxUses   int   `json:"max_uses"`   // One when zero
+}
+
+// CreateInvite handles an admin issuing an invite to a chat
+func (h *ChatHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req createInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	ttl := service.DefaultInviteTTL
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if req.MaxUses == 0 {
+		req.MaxUses = 1
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invite, err := h.service.CreateInvite(r.Context(), chatID, userID, ttl, req.MaxUses)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(invite); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// ListInvites handles listing a chat's invites and who accepted them
+func (h *ChatHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invites, err := h.service.ListInvites(r.Context(), chatID, userID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(invites); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// RevokeInvite handles an admin revoking an invite
+func (h *ChatHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := uuid.Parse(vars["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	inviteID, err := uuid.Parse(vars["inviteId"])
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RevokeInvite(r.Context(), chatID, inviteID, userID); err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvite handles a user joining a chat through an invite
+func (h *ChatHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	chat, err := h.service.AcceptInvite(r.Context(), token, userID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(chat); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package transport
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// updateChatRequest changes the fields that are set
+type updateChatRequest struct {
+	Name       *string               `json:"name"`
+	Visibility *model.ChatVisibility `json:"visibility"`
+}
+
+type setRoleRequest struct {
+	Role model.ChatRole `json:"role"`
+}
+
+type transferOwnershipRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type addMemberRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// MembersResponse is a page of a chat's members, in the order they joined
+type MembersResponse struct {
+	Members []*model.ChatMember `json:"members"`
+	// Cursor to pass as cursor for the next page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListMembers handles listing the members of a chat
+func (h *ChatHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	var query model.MemberQuery
+	if cursor := params.Get("cursor"); cursor != "" {
+		after, err := model.ParseMemberCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		query.After = &after
+	}
+
+	limit := 50 // Default limit
+	if limitStr := params.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+			limit = l
+		}
+	}
+	// Fetch one extra member to learn whether another page follows
+	query.Limit = limit + 1
+
+	members, err := h.service.ListMembers(r.Context(), chatID, userID, query)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	resp := MembersResponse{Members: members}
+	if len(members) > limit {
+		resp.Members = members[:limit]
+		resp.NextCursor = model.MemberCursorAt(resp.Members[limit-1]).Encode()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// AddMember handles an admin adding a user to a chat
+func (h *ChatHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req addMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	memberID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.AddMember(r.Context(), chatID, userID, memberID); err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember handles an admin removing a member from a chat
+func (h *ChatHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := uuid.Parse(vars["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	memberID, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RemoveMember(r.Context(), chatID, userID, memberID); err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateChat handles renaming a chat and changing its visibility
+func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req updateChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Name == nil && req.Visibility == nil) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if req.Visibility != nil {
+		if err := h.service.SetVisibility(r.Context(), chatID, userID, *req.Visibility); err != nil {
+			writeChatError(w, err)
+			return
+		}
+	}
+	if req.Name != nil {
+		if err := h.service.RenameChat(r.Context(), chatID, userID, *req.Name); err != nil {
+			writeChatError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetMemberRole handles changing the role of a chat member
+func (h *ChatHandler) SetMemberRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chatID, err := uuid.Parse(vars["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	memberID, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req setRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.SetMemberRole(r.Context(), chatID, userID, memberID, req.Role); err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TransferOwnership handles the owner handing a chat to another member
+func (h *ChatHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	var req transferOwnershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	newOwnerID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.TransferOwnership(r.Context(), chatID, userID, newOwnerID); err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"rtcs/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// MentionsResponse is a page of the caller's mention inbox, newest first
+type MentionsResponse struct {
+	Mentions    []*model.InboxMention `json:"mentions"`
+	UnreadCount int64                 `json:"unread_count"`
+	// Cursor to pass as cursor for the next page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GetMentions handles listing the messages that mention the caller
+func (h *MessageHandler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+	query := model.MentionQuery{UnreadOnly: params.Get("unread") == "true"}
+	if cursor := params.Get("cursor"); cursor != "" {
+		before, err := model.ParseMessageCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		query.Before = &before
+	}
+
+	limit := 50 // Default limit
+	if limitStr := params.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+	// Fetch one extra mention to learn whether another page follows
+	query.Limit = limit + 1
+
+	mentions, err := h.messageService.GetMentions(r.Context(), userID.String(), query)
+	if err != nil {
+		log.Printf("Error loading mentions of %s: %v", userID, err)
+		http.Error(w, "Failed to load mentions", http.StatusInternalServerError)
+		return
+	}
+	unread, err := h.messageService.CountUnreadMentions(r.Context(), userID.String())
+	if err != nil {
+		log.Printf("Error counting mentions of %s: %v", userID, err)
+		http.Error(w, "Failed to load mentions", http.StatusInternalServerError)
+		return
+	}
+
+	resp := MentionsResponse{Mentions: mentions, UnreadCount: unread}
+	if len(mentions) > limit {
+		resp.Mentions = mentions[:limit]
+		resp.NextCursor = model.CursorAt(resp.Mentions[limit-1].Message).Encode()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
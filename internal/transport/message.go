@@ -1,11 +1,14 @@
 package transport
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
+	"rtcs/internal/model"
 	"rtcs/internal/service"
 
 	"github.com/google/uuid"
@@ -14,8 +17,37 @@ import (
 
 // SendMessageRequest represents the request body for sending a message
 type SendMessageRequest struct {
-	ChatID string `json:"chat_id"`
-	Text   string `json:"text"`
+	ChatID   string `json:"chat_id"`
+	ParentID string `json:"parent_id,omitempty"` // Set to reply in a thread
+	Text     string `json:"text"`
+	// Files uploaded to the chat beforehand; text may be empty if set
+	AttachmentIDs []string `json:"attachment_ids,omitempty"`
+}
+
+// ThreadResponse is a thread root with a page of its replies
+type ThreadResponse struct {
+	Parent  *model.Message   `json:"parent"`
+	Replies []*model.Message `json:"replies"`
+	// Seq to pass as after for the next page, zero on the last page
+	NextAfter int64 `json:"next_after,omitempty"`
+}
+
+// HistoryResponse is a page of chat history, newest first
+type HistoryResponse struct {
+	Messages []*model.Message `json:"messages"`
+	// Cursor to pass as the same parameter for the next page, empty on the
+	// last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// EditMessageRequest represents the request body for editing a message
+type EditMessageRequest struct {
+	Text string `json:"text"`
+}
+
+// ReactionRequest represents the request body for reacting to a message
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
 }
 
 // MessageHandler handles message-related requests
@@ -59,10 +91,23 @@ func (h *MessageHandler) Send(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	message, err := h.messageService.SendMessage(r.Context(), req.ChatID, userID.String(), req.Text)
+	message, err := h.messageService.SendWithAttachments(r.Context(), req.ChatID, userID.String(), req.ParentID, req.Text, req.AttachmentIDs)
 	if err != nil {
 		log.Printf("Error sending message: %v", err)
-		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, service.ErrMessageNotFound):
+			http.Error(w, "Parent message not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrChatNotFound):
+			http.Error(w, "Chat not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrAttachmentNotFound), errors.Is(err, service.ErrInvalidAttachment):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrPermissionDenied):
+			http.Error(w, "Read-only members cannot send messages", http.StatusForbidden)
+		case errors.Is(err, service.ErrNotChatMember):
+			http.Error(w, "Join the chat before sending to it", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		}
 		return
 	}
 	log.Printf("Message sent successfully: %+v", message)
@@ -106,22 +151,234 @@ func (h *MessageHandler) GetChatHistory(w http.ResponseWriter, r *http.Request)
 
 	limit := 50 // Default limit
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
 			limit = l
 		}
 	}
 	log.Printf("Using limit: %d", limit)
 
-	messages, err := h.messageService.GetChatHistory(r.Context(), chatID, limit)
+	query, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Fetch one extra message to learn whether another page follows
+	query.Limit = limit + 1
+
+	messages, err := h.messageService.GetChatHistoryForUser(r.Context(), chatID, userID.String(), query)
 	if err != nil {
 		log.Printf("Error getting chat history: %v", err)
+		if errors.Is(err, service.ErrNotChatMember) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		http.Error(w, "Failed to get chat history", http.StatusInternalServerError)
 		return
 	}
 	log.Printf("Retrieved %d messages", len(messages))
 
+	resp := HistoryResponse{Messages: messages}
+	if len(messages) > limit {
+		// Pages are newest first, so the extra message is the last one when
+		// paging back and the first one when paging forward
+		if query.After != nil {
+			resp.Messages = messages[1:]
+			resp.NextCursor = model.CursorAt(resp.Messages[0]).Encode()
+		} else {
+			resp.Messages = messages[:limit]
+			resp.NextCursor = model.CursorAt(resp.Messages[limit-1]).Encode()
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(messages); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// parseHistoryQuery reads the optional before or after cursor of a history
+// request
+func parseHistoryQuery(r *http.Request) (model.HistoryQuery, error) {
+	var query model.HistoryQuery
+	before := r.URL.Query().Get("before")
+	after := r.URL.Query().Get("after")
+	if before != "" && after != "" {
+		return query, errors.New("Use either before or after, not both")
+	}
+
+	if before != "" {
+		cursor, err := model.ParseMessageCursor(before)
+		if err != nil {
+			return query, errors.New("Invalid before cursor")
+		}
+		query.Before = &cursor
+	}
+	if after != "" {
+		cursor, err := model.ParseMessageCursor(after)
+		if err != nil {
+			return query, errors.New("Invalid after cursor")
+		}
+		query.After = &cursor
+	}
+	return query, nil
+}
+
+// GetThread handles retrieving a page of replies to a message
+func (h *MessageHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	messageID := vars["messageId"]
+	if _, err := uuid.Parse(messageID); err != nil {
+		log.Printf("Error parsing message ID: %v", err)
+		http.Error(w, "Invalid message ID format", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50 // Default limit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+	var after int64
+	if afterStr := r.URL.Query().Get("after"); afterStr != "" {
+		a, err := strconv.ParseInt(afterStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid after parameter", http.StatusBadRequest)
+			return
+		}
+		after = a
+	}
+
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Fetch one extra reply to learn whether another page follows
+	parent, replies, err := h.messageService.GetThread(r.Context(), messageID, userID.String(), after, limit+1)
+	if err != nil {
+		log.Printf("Error getting thread %s: %v", messageID, err)
+		if errors.Is(err, service.ErrMessageNotFound) {
+			http.Error(w, "Thread not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrNotChatMember) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to get thread", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ThreadResponse{Parent: parent, Replies: replies}
+	if len(replies) > limit {
+		resp.Replies = replies[:limit]
+		resp.NextAfter = resp.Replies[limit-1].Seq
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// AddReaction handles adding the caller's reaction to a message
+func (h *MessageHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.handleReaction(w, r, req.Emoji, h.messageService.AddReaction)
+}
+
+// RemoveReaction handles removing the caller's reaction from a message
+func (h *MessageHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	h.handleReaction(w, r, mux.Vars(r)["emoji"], h.messageService.RemoveReaction)
+}
+
+func (h *MessageHandler) handleReaction(w http.ResponseWriter, r *http.Request, emoji string, react func(ctx context.Context, messageID, userID, emoji string) error) {
+	messageID := mux.Vars(r)["messageId"]
+	if _, err := uuid.Parse(messageID); err != nil {
+		log.Printf("Error parsing message ID: %v", err)
+		http.Error(w, "Invalid message ID format", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := react(r.Context(), messageID, userID.String(), emoji); err != nil {
+		log.Printf("Error updating reaction on message %s: %v", messageID, err)
+		switch {
+		case errors.Is(err, service.ErrInvalidReaction):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNotChatMember), errors.Is(err, service.ErrPermissionDenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to update reaction", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EditMessage handles message edits by the sender
+func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	messageID := vars["messageId"]
+	if _, err := uuid.Parse(messageID); err != nil {
+		log.Printf("Error parsing message ID: %v", err)
+		http.Error(w, "Invalid message ID format", http.StatusBadRequest)
+		return
+	}
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		http.Error(w, "Message text cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	message, err := h.messageService.EditMessage(r.Context(), messageID, userID.String(), req.Text)
+	if err != nil {
+		log.Printf("Error editing message %s: %v", messageID, err)
+		switch {
+		case errors.Is(err, service.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNotMessageSender):
+			http.Error(w, "Only the sender can edit a message", http.StatusForbidden)
+		case errors.Is(err, service.ErrNotChatMember):
+			http.Error(w, "Not a member of this chat", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to edit message", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(message); err != nil {
 		log.Printf("Error encoding response: %v", err)
 	}
 }
@@ -147,9 +404,19 @@ func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("User ID from context: %s", userID.String())
 
-	if err := h.messageService.DeleteMessage(r.Context(), messageID, userID.String()); err != nil {
+	// An optional reason is recorded on the tombstone
+	reason := r.URL.Query().Get("reason")
+
+	if err := h.messageService.DeleteMessageWithReason(r.Context(), messageID, userID.String(), reason); err != nil {
 		log.Printf("Error deleting message: %v", err)
-		http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, service.ErrMessageNotFound):
+			http.Error(w, "Message not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNotMessageSender):
+			http.Error(w, "Only the sender or a chat admin can delete a message", http.StatusForbidden)
+		default:
+			http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+		}
 		return
 	}
 	log.Printf("Message deleted successfully")
@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"rtcs/internal/mqtt"
+	"rtcs/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// Access levels in the broker's ACL checks
+const (
+	mqttAccessRead      = 1
+	mqttAccessWrite     = 2
+	mqttAccessSubscribe = 4
+)
+
+// MQTTAuthHandler answers the broker's authentication and ACL checks. Users
+// connect with their user ID as the username and a JWT as the password; the
+// server connects with its own account, which may use every topic.
+//
+// Everyone else may only read the messages of chats they belong to, publish
+// to those chats' send topics under their own ID and read presence. The
+// cluster topics are left to the server account.
+type MQTTAuthHandler struct {
+	authService *service.AuthService
+	chatService *service.ChatService
+	server      mqtt.Credentials
+}
+
+// MQTTAuthRequest is what the broker sends with each check; fields that do
+// not apply to a check are left empty
+type MQTTAuthRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	ClientID string `json:"clientid"`
+	Topic    string `json:"topic"`
+	Acc      int    `json:"acc"`
+}
+
+// NewMQTTAuthHandler creates a handler for the broker's checks. Without a
+// server password the server account is disabled.
+func NewMQTTAuthHandler(authService *service.AuthService, chatService *service.ChatService, server mqtt.Credentials) *MQTTAuthHandler {
+	return &MQTTAuthHandler{
+		authService: authService,
+		chatService: chatService,
+		server:      server,
+	}
+}
+
+// User authenticates a connecting client
+func (h *MQTTAuthHandler) User(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeMQTTAuthRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if h.isServer(req.Username) {
+		if subtle.ConstantTimeCompare([]byte(req.Password), []byte(h.server.Password)) == 1 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		log.Printf("[WARN] MQTT client %s offered a wrong password for the server account", req.ClientID)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	userID, err := h.authService.ValidateToken(r.Context(), req.Password)
+	if err != nil || userID.String() != req.Username {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Superuser reports whether a client may use every topic
+func (h *MQTTAuthHandler) Superuser(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeMQTTAuthRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if h.isServer(req.Username) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(http.StatusForbidden)
+}
+
+// ACL reports whether a user may read, publish or subscribe to a topic
+func (h *MQTTAuthHandler) ACL(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeMQTTAuthRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.allowed(r.Context(), req.Username, req.Topic, req.Acc) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *MQTTAuthHandler) isServer(username string) bool {
+	return h.server.Password != "" && username == h.server.Username
+}
+
+func (h *MQTTAuthHandler) allowed(ctx context.Context, username, topic string, acc int) bool {
+	userID, err := uuid.Parse(username)
+	if err != nil {
+		return false
+	}
+
+	switch acc {
+	case mqttAccessRead, mqttAccessSubscribe:
+		if strings.HasPrefix(topic, mqtt.PresencePrefix) {
+			return true
+		}
+		chatIDStr, ok := mqtt.ParseChatTopic(topic)
+		return ok && h.isMember(ctx, chatIDStr, userID)
+
+	case mqttAccessWrite:
+		// Users may only send as themselves
+		chatIDStr, senderID, ok := mqtt.ParseSendTopic(topic)
+		return ok && senderID == userID.String() && h.isMember(ctx, chatIDStr, userID)
+	}
+	return false
+}
+
+// isMember checks membership of a chat named in a topic; wildcards are not
+// chat IDs, so they never pass
+func (h *MQTTAuthHandler) isMember(ctx context.Context, chatIDStr string, userID uuid.UUID) bool {
+	chatID, err := uuid.Parse(chatIDStr)
+	if err != nil {
+		return false
+	}
+	if err := h.chatService.RequireMember(ctx, chatID, userID); err != nil {
+		if err != service.ErrNotChatMember {
+			log.Printf("[ERROR] Failed to check MQTT access of %s to chat %s: %v", userID, chatID, err)
+		}
+		return false
+	}
+	return true
+}
+
+func decodeMQTTAuthRequest(w http.ResponseWriter, r *http.Request) (*MQTTAuthRequest, bool) {
+	var req MQTTAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
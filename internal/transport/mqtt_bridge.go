@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"rtcs/internal/model"
+	"rtcs/internal/mqtt"
+	"rtcs/internal/service"
+)
+
+// mqttShareGroup makes every server node share one subscription to client
+// topics, so each incoming message is stored exactly once
+const mqttShareGroup = "rtcs"
+
+// MQTTBridgeOptions configures delivery guarantees for the MQTT bridge
+type MQTTBridgeOptions struct {
+	QoS            byte
+	RetainMessages bool // Keep the last message of each chat on the broker
+	RetainPresence bool // Keep each user's last status on the broker
+}
+
+// MQTTBridge lets MQTT clients take part in chats through the broker. It
+// publishes chat messages and presence changes, and stores messages that
+// clients publish to rtcs/chats/{id}/send/{user}.
+//
+// Clients authenticate to the broker, which asks MQTTAuthHandler who may
+// use which topic; the bridge relies on that to keep chats to their members
+// and to trust the user ID in send topics.
+type MQTTBridge struct {
+	publisher      *mqtt.Publisher
+	subscriber     *mqtt.Subscriber
+	options        MQTTBridgeOptions
+	chatService    *service.ChatService
+	messageService *service.MessageService
+}
+
+// MQTTSendRequest is the payload clients publish to a chat's send topic
+type MQTTSendRequest struct {
+	Text string `json:"text"`
+}
+
+// MQTTMessageEvent is published to rtcs/chats/{id}/messages
+type MQTTMessageEvent struct {
+	Type      string         `json:"type"`
+	ChatID    string         `json:"chat_id"`
+	MessageID string         `json:"message_id,omitempty"`
+	Message   *model.Message `json:"message,omitempty"`
+}
+
+// MQTTPresenceEvent is published to rtcs/presence/{user}
+type MQTTPresenceEvent struct {
+	UserID    string    `json:"user_id"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewMQTTBridge connects to the broker with the server account and
+// subscribes to client send topics
+func NewMQTTBridge(broker, clientID string, credentials mqtt.Credentials, options MQTTBridgeOptions, chatService *service.ChatService, messageService *service.MessageService) (*MQTTBridge, error) {
+	publisher, err := mqtt.NewPublisher(broker, clientID+"-bridge-pub", credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &MQTTBridge{
+		publisher:      publisher,
+		options:        options,
+		chatService:    chatService,
+		messageService: messageService,
+	}
+
+	subscriber, err := mqtt.NewSubscriber(broker, clientID+"-bridge-sub", credentials, b.handleSend)
+	if err != nil {
+		publisher.Disconnect()
+		return nil, err
+	}
+	if err := subscriber.SubscribeWithQoS(mqtt.SharedTopic(mqttShareGroup, mqtt.ChatSendWildcard), options.QoS); err != nil {
+		subscriber.Disconnect()
+		publisher.Disconnect()
+		return nil, err
+	}
+	b.subscriber = subscriber
+
+	return b, nil
+}
+
+// HandleEvent publishes domain events to the broker
+func (b *MQTTBridge) HandleEvent(ctx context.Context, event service.Event) {
+	var (
+		topic    string
+		payload  interface{}
+		retained bool
+	)
+
+	switch event.Type {
+	case service.EventMessageCreated:
+		topic = mqtt.ChatMessagesTopic(event.ChatID.String())
+		payload = MQTTMessageEvent{Type: event.Type, ChatID: event.ChatID.String(), Message: event.Message}
+		retained = b.options.RetainMessages
+
+	case service.EventMessageEdited, service.EventMessageDeleted:
+		topic = mqtt.ChatMessagesTopic(event.ChatID.String())
+		payload = MQTTMessageEvent{Type: event.Type, ChatID: event.ChatID.String(), MessageID: event.Message.ID.String(), Message: event.Message}
+		// The retained copy of the latest message must not keep text that
+		// was edited or deleted; a deleted one is replaced by its tombstone
+		retained = b.options.RetainMessages && b.isLatest(ctx, event.Message)
+
+	case service.EventPresenceChanged:
+		topic = mqtt.PresenceTopic(event.UserID.String())
+		payload = MQTTPresenceEvent{UserID: event.UserID.String(), Status: event.Status, Timestamp: time.Now()}
+		retained = b.options.RetainPresence
+
+	default:
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal MQTT event: %v", err)
+		return
+	}
+
+	if err := b.publisher.PublishWithOptions(topic, data, b.options.QoS, retained); err != nil {
+		log.Printf("[ERROR] Failed to publish %s to %s: %v", event.Type, topic, err)
+	}
+}
+
+// isLatest reports whether a message is the newest of its chat, which is
+// the one the broker retains. When unsure it reports true, since retaining
+// an older message's update only leaves new subscribers a staler view.
+func (b *MQTTBridge) isLatest(ctx context.Context, message *model.Message) bool {
+	chat, err := b.chatService.GetChat(ctx, message.ChatID)
+	if err != nil || chat == nil {
+		log.Printf("[WARN] Failed to look up chat %s of message %s: %v", message.ChatID, message.ID, err)
+		return true
+	}
+	return message.Seq >= chat.LastSeq
+}
+
+// handleSend stores a message published by an MQTT client
+func (b *MQTTBridge) handleSend(topic string, payload []byte) {
+	chatIDStr, userIDStr, ok := mqtt.ParseSendTopic(topic)
+	if !ok {
+		log.Printf("[WARN] Ignoring MQTT message on unexpected topic %s", topic)
+		return
+	}
+
+	var req MQTTSendRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		log.Printf("[WARN] Ignoring malformed MQTT message on %s: %v", topic, err)
+		return
+	}
+
+	ctx := context.Background()
+	// The broker only lets users publish to send topics with their own
+	// ID. Membership is checked by the message service. Delivery back to
+	// MQTT and WebSocket clients happens through the message_created event
+	if _, err := b.messageService.SendMessage(ctx, chatIDStr, userIDStr, req.Text); err != nil {
+		log.Printf("[WARN] Rejected MQTT message on %s: %v", topic, err)
+	}
+}
+
+// Close disconnects from the broker
+func (b *MQTTBridge) Close() {
+	b.subscriber.Disconnect()
+	b.publisher.Disconnect()
+}
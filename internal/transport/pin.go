@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PinMessage handles pinning a message to a chat
+func (h *ChatHandler) PinMessage(w http.ResponseWriter, r *http.Request) {
+	h.handlePin(w, r, h.service.PinMessage)
+}
+
+// UnpinMessage handles unpinning a message from a chat
+func (h *ChatHandler) UnpinMessage(w http.ResponseWriter, r *http.Request) {
+	h.handlePin(w, r, h.service.UnpinMessage)
+}
+
+func (h *ChatHandler) handlePin(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, chatID, messageID, userID uuid.UUID) error) {
+	vars := mux.Vars(r)
+	chatID, err := uuid.Parse(vars["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+	messageID, err := uuid.Parse(vars["messageId"])
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := change(r.Context(), chatID, messageID, userID); err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPins handles listing the pinned messages of a chat
+func (h *ChatHandler) ListPins(w http.ResponseWriter, r *http.Request) {
+	chatID, err := uuid.Parse(mux.Vars(r)["chatId"])
+	if err != nil {
+		http.Error(w, "Invalid chat ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pins, err := h.service.ListPins(r.Context(), chatID, userID)
+	if err != nil {
+		writeChatError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pins); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package transport
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"rtcs/internal/model"
 	"rtcs/internal/service"
@@ -10,6 +13,9 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// maxAvatarSize bounds uploaded avatar images; only thumbnails are kept
+const maxAvatarSize = 5 << 20
+
 // ProfileHandler handles profile-related requests
 type ProfileHandler struct {
 	profileService *service.ProfileService
@@ -106,3 +112,80 @@ func (h *ProfileHandler) GetMyProfile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(profile)
 }
+
+// UploadAvatar handles a multipart upload of an avatar image in the "file"
+// field
+func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+multipartOverhead)
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Avatar image too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxAvatarSize {
+		http.Error(w, "Avatar image too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	profile, err := h.profileService.UploadAvatar(r.Context(), userID, file)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImage) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error uploading avatar of user %s: %v", userID, err)
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// GetAvatar serves an avatar thumbnail. Avatars are public like profiles,
+// and each upload gets new file names, so they are cached indefinitely.
+func (h *ProfileHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := uuid.Parse(vars["userId"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	content, err := h.profileService.OpenAvatar(r.Context(), userID, vars["file"])
+	if err != nil {
+		if errors.Is(err, service.ErrAvatarNotFound) {
+			http.Error(w, "Avatar not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error opening avatar %s of user %s: %v", vars["file"], userID, err)
+		http.Error(w, "Failed to load avatar", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error sending avatar %s: %v", vars["file"], err)
+	}
+}
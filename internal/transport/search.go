@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rtcs/internal/model"
+	"rtcs/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// SearchResponse is a page of search results, newest first
+type SearchResponse struct {
+	Results []*model.SearchResult `json:"results"`
+	// Cursor to pass as cursor for the next page, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// SearchMessages handles full-text search over the caller's chats
+func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("user_id").(uuid.UUID)
+	if !ok {
+		log.Printf("Error: user_id not found in context or wrong type")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 20 // Default limit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 50 {
+			limit = l
+		}
+	}
+	// Fetch one extra result to learn whether another page follows
+	query.Limit = limit + 1
+
+	results, err := h.messageService.SearchMessages(r.Context(), userID.String(), query)
+	if err != nil {
+		log.Printf("Error searching messages: %v", err)
+		if errors.Is(err, service.ErrInvalidSearch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to search messages", http.StatusInternalServerError)
+		return
+	}
+
+	resp := SearchResponse{Results: results}
+	if len(results) > limit {
+		resp.Results = results[:limit]
+		resp.NextCursor = model.CursorAt(resp.Results[limit-1].Message).Encode()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// parseSearchQuery reads the search text and filters of a search request
+func parseSearchQuery(r *http.Request) (model.SearchQuery, error) {
+	params := r.URL.Query()
+	query := model.SearchQuery{Text: params.Get("q")}
+
+	if chatID := params.Get("chat_id"); chatID != "" {
+		id, err := uuid.Parse(chatID)
+		if err != nil {
+			return query, errors.New("Invalid chat ID format")
+		}
+		query.ChatID = &id
+	}
+	if senderID := params.Get("sender_id"); senderID != "" {
+		id, err := uuid.Parse(senderID)
+		if err != nil {
+			return query, errors.New("Invalid sender ID format")
+		}
+		query.SenderID = &id
+	}
+	if from := params.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return query, errors.New("Invalid from time, expected RFC 3339")
+		}
+		query.From = &t
+	}
+	if to := params.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return query, errors.New("Invalid to time, expected RFC 3339")
+		}
+		query.To = &t
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		c, err := model.ParseMessageCursor(cursor)
+		if err != nil {
+			return query, errors.New("Invalid cursor")
+		}
+		query.Before = &c
+	}
+	return query, nil
+}
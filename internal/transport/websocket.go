@@ -3,12 +3,15 @@ package transport
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"rtcs/internal/cluster"
 	"rtcs/internal/model"
 	"rtcs/internal/service"
 
@@ -26,11 +29,16 @@ const (
 	messagesPerSecond = 5               // Rate limit: messages per second per client
 	heartbeatInterval = 5 * time.Second // Reduced interval for more frequent updates
 	statusInterval    = 5 * time.Second // How often to broadcast status updates
+
+	// bearerSubprotocol is offered alongside the token in Sec-WebSocket-Protocol
+	// by browser clients, which cannot set the Authorization header
+	bearerSubprotocol = "rtcs.bearer"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{bearerSubprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
 		log.Printf("Accepting WebSocket connection from origin: %s", origin)
@@ -41,6 +49,8 @@ var upgrader = websocket.Upgrader{
 type Client struct {
 	conn     *websocket.Conn
 	userID   string
+	chats    map[string]bool             // Chats this client is subscribed to, guarded by handler.clientsMux
+	resuming map[string][]*chatBroadcast // Live frames held back during replay, guarded by handler.clientsMux
 	send     chan []byte
 	handler  *WebSocketHandler
 	limiter  *rate.Limiter
@@ -48,20 +58,31 @@ type Client struct {
 	closeMux sync.RWMutex
 	ctx      context.Context
 	cancel   context.CancelFunc
+
+	typing    map[string]*typingState // Active typing indicators by chat ID
+	typingMux sync.Mutex
 }
 
 type WebSocketHandler struct {
 	clients        map[*Client]bool
 	clientsMux     sync.RWMutex
 	broadcast      chan []byte
+	chatcast       chan *chatBroadcast
 	register       chan *Client
 	unregister     chan *Client
 	stats          *WebSocketStats
 	shutdown       chan struct{}
-	userIDs        map[string]*Client
-	knownUsers     map[string]bool // Track all users who have ever connected
+	userIDs        map[string]map[*Client]bool // Open connections per user
+	knownUsers     map[string]bool             // Track all users who have ever connected
+	rooms          map[string]map[*Client]bool // Subscribed clients per chat ID
+	authService    *service.AuthService
+	chatService    *service.ChatService
+	messageService *service.MessageService
 	statusService  *service.StatusService
 	profileService *service.ProfileService
+	backplane      cluster.Backplane // Optional; relays frames to other nodes
+	nodeID         string
+	events         service.EventPublisher // Optional; receives presence changes
 }
 
 type WebSocketStats struct {
@@ -71,27 +92,56 @@ type WebSocketStats struct {
 	Errors            int64
 }
 
+// chatBroadcast is a frame addressed to the subscribers of a single chat
+type chatBroadcast struct {
+	chatID  string
+	seq     int64 // Sequence of the message the frame delivers, zero for other frames
+	message []byte
+
+	// User whose subscriptions to the chat end once the frame is sent
+	unsubscribe string
+}
+
 type WebSocketMessage struct {
-	Type     string                        `json:"type"`
-	UserID   string                        `json:"userId,omitempty"`
-	Text     string                        `json:"text,omitempty"`
-	Sender   string                        `json:"sender,omitempty"`
-	Users    []string                      `json:"users,omitempty"`
-	Status   string                        `json:"status,omitempty"`
-	Statuses map[string]string             `json:"statuses,omitempty"`
-	Profiles map[string]*model.UserProfile `json:"profiles,omitempty"`
+	Type      string                        `json:"type"`
+	UserID    string                        `json:"userId,omitempty"`
+	ChatID    string                        `json:"chatId,omitempty"`
+	MessageID string                        `json:"messageId,omitempty"`
+	Seq       int64                         `json:"seq,omitempty"`
+	ParentID  string                        `json:"parentId,omitempty"`
+	Emoji     string                        `json:"emoji,omitempty"`
+	Text      string                        `json:"text,omitempty"`
+	Sender    string                        `json:"sender,omitempty"`
+	Users     []string                      `json:"users,omitempty"`
+	Status    string                        `json:"status,omitempty"`
+	Statuses  map[string]string             `json:"statuses,omitempty"`
+	Profiles  map[string]*model.UserProfile `json:"profiles,omitempty"`
+	Message   *model.Message                `json:"message,omitempty"`
+
+	// Files uploaded beforehand to send with a message
+	AttachmentIDs []string `json:"attachmentIds,omitempty"`
+
+	// Whether a pins_changed frame pinned or unpinned the message
+	Pinned *bool `json:"pinned,omitempty"`
+	// New role of the member in a member_role_changed frame
+	Role string `json:"role,omitempty"`
 }
 
-func NewWebSocketHandler(statusService *service.StatusService, profileService *service.ProfileService) *WebSocketHandler {
+func NewWebSocketHandler(authService *service.AuthService, chatService *service.ChatService, messageService *service.MessageService, statusService *service.StatusService, profileService *service.ProfileService) *WebSocketHandler {
 	h := &WebSocketHandler{
 		clients:        make(map[*Client]bool),
 		broadcast:      make(chan []byte, 256),
+		chatcast:       make(chan *chatBroadcast, 256),
 		register:       make(chan *Client),
 		unregister:     make(chan *Client),
 		stats:          &WebSocketStats{},
 		shutdown:       make(chan struct{}),
-		userIDs:        make(map[string]*Client),
+		userIDs:        make(map[string]map[*Client]bool),
 		knownUsers:     make(map[string]bool), // Initialize knownUsers map
+		rooms:          make(map[string]map[*Client]bool),
+		authService:    authService,
+		chatService:    chatService,
+		messageService: messageService,
 		statusService:  statusService,
 		profileService: profileService,
 	}
@@ -107,48 +157,66 @@ func (h *WebSocketHandler) run() {
 		case client := <-h.register:
 			h.clientsMux.Lock()
 			h.clients[client] = true
+			first := h.attachPresence(client)
+			h.knownUsers[client.userID] = true // Add to known users
+			for chatID := range client.chats {
+				h.joinRoom(client, chatID)
+			}
 			h.clientsMux.Unlock()
 			atomic.AddInt64(&h.stats.ActiveConnections, 1)
 
-		case client := <-h.unregister:
-			h.clientsMux.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-
-				if client.userID != "" {
-					// Remove from active clients but keep in knownUsers
-					delete(h.userIDs, client.userID)
-
-					if h.statusService != nil {
-						ctx := context.Background()
-						if err := h.statusService.SetUserOffline(ctx, client.userID); err != nil {
-							log.Printf("[ERROR] Failed to set user %s offline: %v", client.userID, err)
-						} else {
-							log.Printf("[INFO] Set user %s offline during unregister", client.userID)
-							// Broadcast the status change
-							h.broadcastUserStatus(client.userID, "offline")
-							// Update all clients with the latest user list
-							h.broadcastUserList()
-						}
-					}
-				}
+			// Presence updates broadcast through this loop, so they must not
+			// run on it. Only a user's first connection brings them online;
+			// later ones just need the user list.
+			if first {
+				go h.userJoined(client.userID)
+			} else {
+				go h.broadcastUserList()
 			}
-			h.clientsMux.Unlock()
-			atomic.AddInt64(&h.stats.ActiveConnections, -1)
+
+		case client := <-h.unregister:
+			h.removeClient(client)
 
 		case message := <-h.broadcast:
+			var slow []*Client
 			h.clientsMux.RLock()
 			for client := range h.clients {
 				select {
 				case client.send <- message:
 					atomic.AddInt64(&h.stats.MessagesSent, 1)
 				default:
-					client.close()
-					h.unregister <- client
+					slow = append(slow, client)
 				}
 			}
 			h.clientsMux.RUnlock()
+			h.dropClients(slow)
+
+		case msg := <-h.chatcast:
+			var slow []*Client
+			h.clientsMux.Lock()
+			for client := range h.rooms[msg.chatID] {
+				// Clients replaying this chat get live frames once caught up
+				if pending, ok := client.resuming[msg.chatID]; ok {
+					client.resuming[msg.chatID] = append(pending, msg)
+					continue
+				}
+
+				select {
+				case client.send <- msg.message:
+					atomic.AddInt64(&h.stats.MessagesSent, 1)
+				default:
+					slow = append(slow, client)
+				}
+			}
+			if msg.unsubscribe != "" {
+				for client := range h.rooms[msg.chatID] {
+					if client.userID == msg.unsubscribe {
+						h.leaveRoom(client, msg.chatID)
+					}
+				}
+			}
+			h.clientsMux.Unlock()
+			h.dropClients(slow)
 
 		case <-h.shutdown:
 			h.clientsMux.Lock()
@@ -161,6 +229,211 @@ func (h *WebSocketHandler) run() {
 	}
 }
 
+// removeClient detaches a client from the hub and all of its rooms
+func (h *WebSocketHandler) removeClient(client *Client) {
+	h.clientsMux.Lock()
+	_, ok := h.clients[client]
+	last := false
+	if ok {
+		delete(h.clients, client)
+		close(client.send)
+
+		// Remove from active clients but keep in knownUsers
+		last = h.detachPresence(client)
+		for chatID := range client.chats {
+			h.leaveRoom(client, chatID)
+		}
+	}
+	h.clientsMux.Unlock()
+
+	if ok {
+		atomic.AddInt64(&h.stats.ActiveConnections, -1)
+	}
+	// The user stays online while any of their other connections is open
+	if last {
+		go h.userLeft(client.userID)
+	}
+}
+
+// attachPresence counts a client towards its user being online and reports
+// whether it is the user's only connection; callers must hold clientsMux
+func (h *WebSocketHandler) attachPresence(client *Client) bool {
+	conns, ok := h.userIDs[client.userID]
+	if !ok {
+		conns = make(map[*Client]bool)
+		h.userIDs[client.userID] = conns
+	}
+	if conns[client] {
+		return false
+	}
+	conns[client] = true
+	return len(conns) == 1
+}
+
+// detachPresence stops counting a client towards its user being online and
+// reports whether it was the user's last connection; callers must hold
+// clientsMux
+func (h *WebSocketHandler) detachPresence(client *Client) bool {
+	conns, ok := h.userIDs[client.userID]
+	if !ok || !conns[client] {
+		return false
+	}
+	delete(conns, client)
+	if len(conns) > 0 {
+		return false
+	}
+	delete(h.userIDs, client.userID)
+	return true
+}
+
+// dropClients disconnects clients whose send buffer is full
+func (h *WebSocketHandler) dropClients(clients []*Client) {
+	for _, client := range clients {
+		log.Printf("[WARN] Dropping slow client %s", client.userID)
+		client.close()
+		h.removeClient(client)
+	}
+}
+
+// joinRoom adds a client to a chat room; callers must hold clientsMux
+func (h *WebSocketHandler) joinRoom(client *Client, chatID string) {
+	room, ok := h.rooms[chatID]
+	if !ok {
+		room = make(map[*Client]bool)
+		h.rooms[chatID] = room
+	}
+	room[client] = true
+	client.chats[chatID] = true
+}
+
+// leaveRoom removes a client from a chat room; callers must hold clientsMux
+func (h *WebSocketHandler) leaveRoom(client *Client, chatID string) {
+	if room, ok := h.rooms[chatID]; ok {
+		delete(room, client)
+		if len(room) == 0 {
+			delete(h.rooms, chatID)
+		}
+	}
+	delete(client.chats, chatID)
+}
+
+// subscribe adds a client to a chat room after checking chat membership
+func (h *WebSocketHandler) subscribe(ctx context.Context, client *Client, chatID string) error {
+	chats, err := h.memberChats(ctx, client.userID)
+	if err != nil {
+		return err
+	}
+	if !chats[chatID] {
+		return errors.New("not a member of this chat")
+	}
+
+	h.clientsMux.Lock()
+	h.joinRoom(client, chatID)
+	h.clientsMux.Unlock()
+	return nil
+}
+
+// unsubscribe removes a client from a chat room
+func (h *WebSocketHandler) unsubscribe(client *Client, chatID string) {
+	h.clientsMux.Lock()
+	h.leaveRoom(client, chatID)
+	h.clientsMux.Unlock()
+}
+
+// memberChats returns the IDs of the chats a user belongs to
+func (h *WebSocketHandler) memberChats(ctx context.Context, userIDStr string) (map[string]bool, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	chats, err := h.chatService.ListChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(chats))
+	for _, chat := range chats {
+		ids[chat.ID.String()] = true
+	}
+	return ids, nil
+}
+
+// userJoined marks a freshly authenticated user as online and announces it
+func (h *WebSocketHandler) userJoined(userID string) {
+	log.Printf("[INFO] User joined: %s", userID)
+
+	// Ensure status is set in Redis
+	if h.statusService != nil {
+		ctx := context.Background()
+		if err := h.statusService.SetUserOnline(ctx, userID); err != nil {
+			log.Printf("[ERROR] Failed to set user %s online: %v", userID, err)
+		} else {
+			log.Printf("[INFO] Set user %s online in Redis", userID)
+		}
+	}
+
+	h.broadcastMessage(WebSocketMessage{
+		Type:   "user_join",
+		UserID: userID,
+		Status: "online",
+	})
+	h.publishPresence(userID, "online")
+
+	// Send updated user list to all clients
+	h.broadcastUserList()
+}
+
+// userLeft marks a user whose last connection closed as offline and
+// announces it
+func (h *WebSocketHandler) userLeft(userID string) {
+	log.Printf("[INFO] User left: %s", userID)
+	h.broadcastMessage(WebSocketMessage{
+		Type:   "user_leave",
+		UserID: userID,
+		Status: "offline",
+	})
+
+	if h.statusService == nil {
+		return
+	}
+
+	ctx := context.Background()
+	if err := h.statusService.SetUserOffline(ctx, userID); err != nil {
+		log.Printf("[ERROR] Failed to set user %s offline: %v", userID, err)
+		return
+	}
+	log.Printf("[INFO] Set user %s offline during unregister", userID)
+	h.publishPresence(userID, "offline")
+
+	// Broadcast the status change
+	h.broadcastUserStatus(userID, "offline")
+	// Update all clients with the latest user list
+	h.broadcastUserList()
+}
+
+// SetEventPublisher sets where presence changes of local users are published
+func (h *WebSocketHandler) SetEventPublisher(events service.EventPublisher) {
+	h.events = events
+}
+
+// publishPresence emits a presence event if a publisher is configured
+func (h *WebSocketHandler) publishPresence(userIDStr, status string) {
+	if h.events == nil {
+		return
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return
+	}
+	h.events.Publish(context.Background(), service.Event{
+		Type:   service.EventPresenceChanged,
+		UserID: userID,
+		Status: status,
+	})
+}
+
 // Periodically broadcast status updates to all clients
 func (h *WebSocketHandler) periodicStatusBroadcast() {
 	ticker := time.NewTicker(statusInterval)
@@ -191,27 +464,7 @@ func (c *Client) close() {
 
 func (c *Client) readPump() {
 	defer func() {
-		if c.userID != "" {
-			// Ensure user is marked as offline when connection closes
-			if c.handler.statusService != nil {
-				ctx := context.Background()
-				if err := c.handler.statusService.SetUserOffline(ctx, c.userID); err != nil {
-					log.Printf("[ERROR] Failed to set user %s offline on disconnect: %v", c.userID, err)
-				} else {
-					log.Printf("[INFO] Set user %s offline on disconnect", c.userID)
-				}
-			}
-
-			c.handler.broadcastMessage(WebSocketMessage{
-				Type:   "user_leave",
-				UserID: c.userID,
-				Status: "offline",
-			})
-
-			// Update all clients with the latest user list
-			c.handler.broadcastUserList()
-		}
-
+		c.stopAllTyping()
 		c.handler.unregister <- c
 		c.close()
 		log.Printf("[INFO] WebSocket connection closed: %s", c.userID)
@@ -235,7 +488,7 @@ func (c *Client) readPump() {
 		}
 
 		// Update user status on any activity
-		if c.userID != "" && c.handler.statusService != nil {
+		if c.handler.statusService != nil {
 			ctx := context.Background()
 			if err := c.handler.statusService.SetUserOnline(ctx, c.userID); err != nil {
 				log.Printf("[ERROR] Failed to refresh user status: %v", err)
@@ -252,68 +505,62 @@ func (c *Client) readPump() {
 		var wsMsg WebSocketMessage
 		if err := json.Unmarshal(message, &wsMsg); err != nil {
 			log.Printf("[ERROR] Failed to parse WebSocket message: %v", err)
-			c.handler.broadcastMessage(WebSocketMessage{
-				Type: "message",
-				Text: string(message),
-			})
+			c.sendError("invalid message format")
 			continue
 		}
 
+		// The identity is bound at handshake time; never trust one sent by the client
+		if wsMsg.UserID != "" && wsMsg.UserID != c.userID {
+			log.Printf("[WARN] Ignoring client-supplied user ID %s on connection for %s", wsMsg.UserID, c.userID)
+		}
+		wsMsg.UserID = c.userID
+
 		switch wsMsg.Type {
 		case "user_join":
-			log.Printf("[INFO] User joined: %s", wsMsg.UserID)
+			// Joined during the handshake unless this connection left since;
+			// otherwise just re-announce presence
 			c.handler.clientsMux.Lock()
-			c.userID = wsMsg.UserID
-			c.handler.userIDs[wsMsg.UserID] = c
-			c.handler.knownUsers[wsMsg.UserID] = true // Add to known users
+			first := c.handler.clients[c] && c.handler.attachPresence(c)
 			c.handler.clientsMux.Unlock()
-
-			// Ensure status is set in Redis
-			if c.handler.statusService != nil {
-				ctx := context.Background()
-				if err := c.handler.statusService.SetUserOnline(ctx, wsMsg.UserID); err != nil {
-					log.Printf("[ERROR] Failed to set user %s online: %v", wsMsg.UserID, err)
-				} else {
-					log.Printf("[INFO] Set user %s online in Redis", wsMsg.UserID)
-				}
+			if first {
+				c.handler.userJoined(c.userID)
+				break
 			}
-
-			go c.startHeartbeat()
-
-			// Make sure status is included in the broadcast
 			wsMsg.Status = "online"
 			c.handler.broadcastMessage(wsMsg)
 
-			// Send updated user list to all clients
-			c.handler.broadcastUserList()
-
 		case "user_leave":
-			log.Printf("[INFO] User left: %s", wsMsg.UserID)
-			if c.userID != "" {
-				c.handler.clientsMux.Lock()
-				delete(c.handler.userIDs, c.userID)
-				// Keep in knownUsers
-				c.handler.clientsMux.Unlock()
-
-				if c.handler.statusService != nil {
-					ctx := context.Background()
-					if err := c.handler.statusService.SetUserOffline(ctx, c.userID); err != nil {
-						log.Printf("[ERROR] Failed to set user %s offline: %v", c.userID, err)
-					} else {
-						log.Printf("[INFO] Set user %s offline", c.userID)
-					}
-				}
-
-				wsMsg.Status = "offline"
-				c.handler.broadcastMessage(wsMsg)
+			// The user only goes offline once none of their connections
+			// count towards their presence
+			c.handler.clientsMux.Lock()
+			last := c.handler.detachPresence(c)
+			// Keep in knownUsers
+			c.handler.clientsMux.Unlock()
+			if last {
+				c.handler.userLeft(c.userID)
+			}
 
-				// Broadcast updated user list
-				c.handler.broadcastUserList()
+		case "subscribe":
+			log.Printf("[INFO] User %s subscribing to chat %s", c.userID, wsMsg.ChatID)
+			if err := c.handler.subscribe(c.ctx, c, wsMsg.ChatID); err != nil {
+				log.Printf("[WARN] Subscription of %s to chat %s refused: %v", c.userID, wsMsg.ChatID, err)
+				c.sendError("cannot subscribe to chat: " + err.Error())
+				break
 			}
+			c.sendMessage(WebSocketMessage{Type: "subscribed", ChatID: wsMsg.ChatID})
+
+		case "unsubscribe":
+			log.Printf("[INFO] User %s unsubscribing from chat %s", c.userID, wsMsg.ChatID)
+			c.stopTyping(wsMsg.ChatID)
+			c.handler.unsubscribe(c, wsMsg.ChatID)
+			c.sendMessage(WebSocketMessage{Type: "unsubscribed", ChatID: wsMsg.ChatID})
 
 		case "message":
-			log.Printf("[INFO] Message from %s: %s", c.userID, wsMsg.Text)
-			wsMsg.Sender = c.userID
+			log.Printf("[INFO] Message from %s to chat %s: %s", c.userID, wsMsg.ChatID, wsMsg.Text)
+			if !c.isSubscribed(wsMsg.ChatID) {
+				c.sendError("not subscribed to chat")
+				break
+			}
 
 			// Update status when sending message
 			if c.handler.statusService != nil {
@@ -323,7 +570,57 @@ func (c *Client) readPump() {
 				}
 			}
 
-			c.handler.broadcastMessage(wsMsg)
+			// Delivery happens through the message_created event, so live
+			// frames carry the same ID and timestamp as history
+			if _, err := c.handler.messageService.SendWithAttachments(c.ctx, wsMsg.ChatID, c.userID, wsMsg.ParentID, wsMsg.Text, wsMsg.AttachmentIDs); err != nil {
+				log.Printf("[ERROR] Failed to save message from %s: %v", c.userID, err)
+				c.sendError("failed to send message")
+				break
+			}
+			// Sending a message ends the sender's typing indicator
+			c.stopTyping(wsMsg.ChatID)
+
+		case "typing_start":
+			if !c.isSubscribed(wsMsg.ChatID) {
+				c.sendError("not subscribed to chat")
+				break
+			}
+			c.startTyping(wsMsg.ChatID)
+
+		case "typing_stop":
+			c.stopTyping(wsMsg.ChatID)
+
+		case "edit":
+			log.Printf("[INFO] Edit of message %s from %s", wsMsg.MessageID, c.userID)
+			// The new text reaches the chat through the message_edited event
+			if _, err := c.handler.messageService.EditMessage(c.ctx, wsMsg.MessageID, c.userID, wsMsg.Text); err != nil {
+				log.Printf("[WARN] Edit of message %s from %s failed: %v", wsMsg.MessageID, c.userID, err)
+				c.sendError("cannot edit message: " + err.Error())
+			}
+
+		case "react", "unreact":
+			react := c.handler.messageService.AddReaction
+			if wsMsg.Type == "unreact" {
+				react = c.handler.messageService.RemoveReaction
+			}
+			// Chat members see the change through the reaction event
+			if err := react(c.ctx, wsMsg.MessageID, c.userID, wsMsg.Emoji); err != nil {
+				log.Printf("[WARN] Reaction on message %s from %s failed: %v", wsMsg.MessageID, c.userID, err)
+				c.sendError("cannot update reaction: " + err.Error())
+			}
+
+		case "resume":
+			log.Printf("[INFO] User %s resuming chat %s after seq %d", c.userID, wsMsg.ChatID, wsMsg.Seq)
+			if err := c.resume(wsMsg.ChatID, wsMsg.Seq); err != nil {
+				log.Printf("[WARN] Resume of chat %s for %s failed: %v", wsMsg.ChatID, c.userID, err)
+				c.sendError("cannot resume chat: " + err.Error())
+			}
+
+		case "delivered", "read":
+			if err := c.acknowledge(wsMsg.Type, wsMsg.ChatID, wsMsg.MessageID); err != nil {
+				log.Printf("[WARN] %s receipt from %s for chat %s failed: %v", wsMsg.Type, c.userID, wsMsg.ChatID, err)
+				c.sendError("cannot record receipt: " + err.Error())
+			}
 
 		case "status_request":
 			log.Printf("[INFO] Status request from %s", c.userID)
@@ -336,7 +633,7 @@ func (c *Client) readPump() {
 
 		case "heartbeat":
 			log.Printf("[DEBUG] Heartbeat from %s", c.userID)
-			if c.userID != "" && c.handler.statusService != nil {
+			if c.handler.statusService != nil {
 				ctx := context.Background()
 				if err := c.handler.statusService.SetUserOnline(ctx, c.userID); err != nil {
 					log.Printf("[ERROR] Failed to refresh user status: %v", err)
@@ -344,14 +641,73 @@ func (c *Client) readPump() {
 			}
 
 		default:
-			log.Printf("[WARN] Unknown message type: %s", wsMsg.Type)
-			c.handler.broadcastMessage(wsMsg)
+			log.Printf("[WARN] Unknown message type from %s: %s", c.userID, wsMsg.Type)
+			c.sendError("unknown message type: " + wsMsg.Type)
 		}
 
 		atomic.AddInt64(&c.handler.stats.MessagesReceived, 1)
 	}
 }
 
+// isSubscribed reports whether the client receives frames for a chat
+func (c *Client) isSubscribed(chatID string) bool {
+	if chatID == "" {
+		return false
+	}
+
+	c.handler.clientsMux.RLock()
+	defer c.handler.clientsMux.RUnlock()
+	return c.chats[chatID]
+}
+
+// sendMessage queues a frame for this client only
+func (c *Client) sendMessage(msg WebSocketMessage) {
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal message: %v", err)
+		return
+	}
+
+	if !c.queue(msgBytes, 0) {
+		log.Printf("[WARN] Send buffer full for client %s, dropping frame", c.userID)
+	}
+}
+
+// queue puts a frame on the send buffer, waiting up to wait for room. It
+// reports false if the frame was not queued.
+func (c *Client) queue(msgBytes []byte, wait time.Duration) bool {
+	// The hub closes send only after close(), so holding the read lock keeps
+	// the channel open for the duration of the send
+	c.closeMux.RLock()
+	defer c.closeMux.RUnlock()
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.send <- msgBytes:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case c.send <- msgBytes:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// sendError reports a failed request back to the client
+func (c *Client) sendError(text string) {
+	c.sendMessage(WebSocketMessage{Type: "error", Text: text})
+}
+
 func (c *Client) startHeartbeat() {
 	ticker := time.NewTicker(heartbeatInterval)
 	defer ticker.Stop()
@@ -359,7 +715,7 @@ func (c *Client) startHeartbeat() {
 	for {
 		select {
 		case <-ticker.C:
-			if c.userID != "" && c.handler.statusService != nil {
+			if c.handler.statusService != nil {
 				log.Printf("[DEBUG] Sending server-side heartbeat for user %s", c.userID)
 				ctx := context.Background()
 				if err := c.handler.statusService.SetUserOnline(ctx, c.userID); err != nil {
@@ -422,6 +778,32 @@ func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 
 	log.Printf("[INFO] New WebSocket connection request from %s", r.RemoteAddr)
 
+	// Authenticate before upgrading so unauthenticated clients never get a socket
+	var userID uuid.UUID
+	var err error
+	if token := webSocketToken(r); token != "" {
+		userID, err = h.authService.ValidateToken(r.Context(), token)
+	} else if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		userID, err = h.authService.ValidateWebSocketTicket(r.Context(), ticket)
+	} else {
+		log.Printf("[WARN] WebSocket connection from %s rejected: missing token", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("[WARN] WebSocket connection from %s rejected: %v", r.RemoteAddr, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Subscribe the client to every chat it is a member of
+	chats, err := h.memberChats(r.Context(), userID.String())
+	if err != nil {
+		log.Printf("[ERROR] Failed to load chats for user %s: %v", userID, err)
+		http.Error(w, "Failed to load chats", http.StatusInternalServerError)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("[ERROR] Failed to upgrade connection: %v", err)
@@ -431,19 +813,46 @@ func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &Client{
-		conn:    conn,
-		send:    make(chan []byte, 256),
-		handler: h,
-		limiter: rate.NewLimiter(rate.Limit(messagesPerSecond), 1),
-		ctx:     ctx,
-		cancel:  cancel,
+		conn:     conn,
+		userID:   userID.String(),
+		chats:    chats,
+		resuming: make(map[string][]*chatBroadcast),
+		send:     make(chan []byte, 256),
+		handler:  h,
+		limiter:  rate.NewLimiter(rate.Limit(messagesPerSecond), 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		typing:   make(map[string]*typingState),
 	}
 
-	log.Printf("[INFO] WebSocket connection established from %s", r.RemoteAddr)
+	log.Printf("[INFO] WebSocket connection established from %s for user %s", r.RemoteAddr, client.userID)
 	h.register <- client
 
 	go client.writePump()
 	go client.readPump()
+	go client.startHeartbeat()
+}
+
+// webSocketToken extracts the access token for a WebSocket handshake from
+// the Authorization header or the Sec-WebSocket-Protocol header, in that
+// order. Tickets are only accepted in the ticket query parameter.
+func webSocketToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+			return authHeader[7:]
+		}
+		return authHeader
+	}
+
+	// Browsers offer ["rtcs.bearer", "<token>"] as subprotocols
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == bearerSubprotocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+
+	return ""
 }
 
 func (h *WebSocketHandler) broadcastMessage(msg WebSocketMessage) {
@@ -466,7 +875,192 @@ func (h *WebSocketHandler) broadcastMessage(msg WebSocketMessage) {
 
 	log.Printf("[DEBUG] Broadcasting message: %s", string(messageBytes))
 	h.broadcast <- messageBytes
+	h.relay("", 0, messageBytes)
+
+}
+
+// HandleEvent delivers domain events to the online members of the affected chat
+func (h *WebSocketHandler) HandleEvent(ctx context.Context, event service.Event) {
+	chatID := event.ChatID.String()
+
+	switch event.Type {
+	case service.EventMessageCreated:
+		h.broadcastToChat(chatID, messageCreatedFrame(event.Message))
+
+	case service.EventMessageEdited:
+		h.broadcastToChat(chatID, WebSocketMessage{
+			Type:      event.Type,
+			ChatID:    chatID,
+			MessageID: event.Message.ID.String(),
+			Sender:    event.Message.SenderID.String(),
+			Text:      event.Message.Text,
+			Message:   event.Message,
+		})
+
+	case service.EventMessageDeleted:
+		h.broadcastToChat(chatID, WebSocketMessage{
+			Type:      event.Type,
+			ChatID:    chatID,
+			MessageID: event.Message.ID.String(),
+			Message:   event.Message,
+		})
+
+	case service.EventMessageDelivered, service.EventMessageRead:
+		h.broadcastToChat(chatID, receiptFrame(event))
+
+	case service.EventReactionAdded, service.EventReactionRemoved:
+		h.broadcastToChat(chatID, WebSocketMessage{
+			Type:      event.Type,
+			ChatID:    chatID,
+			MessageID: event.Message.ID.String(),
+			UserID:    event.UserID.String(),
+			Emoji:     event.Emoji,
+		})
+
+	case service.EventPinsChanged:
+		pinned := event.Pinned
+		h.broadcastToChat(chatID, WebSocketMessage{
+			Type:      event.Type,
+			ChatID:    chatID,
+			MessageID: event.Message.ID.String(),
+			UserID:    event.UserID.String(),
+			Pinned:    &pinned,
+		})
+
+	case service.EventThreadReply:
+		userIDs := make([]string, 0, len(event.UserIDs))
+		for _, userID := range event.UserIDs {
+			userIDs = append(userIDs, userID.String())
+		}
+		h.sendToUsers(userIDs, WebSocketMessage{
+			Type:      event.Type,
+			ChatID:    chatID,
+			MessageID: event.Message.ID.String(),
+			ParentID:  event.Message.ParentID.String(),
+			Sender:    event.Message.SenderID.String(),
+			Text:      event.Message.Text,
+			Message:   event.Message,
+		})
+
+	case service.EventChatRenamed:
+		h.broadcastToChat(chatID, WebSocketMessage{
+			Type:   event.Type,
+			ChatID: chatID,
+			UserID: event.UserID.String(),
+			Text:   event.Name,
+		})
+
+	case service.EventRoleChanged:
+		h.broadcastToChat(chatID, WebSocketMessage{
+			Type:   event.Type,
+			ChatID: chatID,
+			UserID: event.TargetID.String(),
+			Sender: event.UserID.String(),
+			Role:   string(event.Role),
+		})
+
+	case service.EventMemberAdded:
+		frame := WebSocketMessage{
+			Type:   event.Type,
+			ChatID: chatID,
+			UserID: event.TargetID.String(),
+			Sender: event.UserID.String(),
+			Role:   string(event.Role),
+		}
+		h.broadcastToChat(chatID, frame)
+		// The new member is not subscribed yet; tell them so they can
+		h.sendToUsers([]string{event.TargetID.String()}, frame)
+
+	case service.EventMemberRemoved:
+		h.broadcastRemoval(chatID, event.TargetID.String(), WebSocketMessage{
+			Type:   event.Type,
+			ChatID: chatID,
+			UserID: event.TargetID.String(),
+			Sender: event.UserID.String(),
+		})
+
+	case service.EventMention:
+		userIDs := make([]string, 0, len(event.UserIDs))
+		for _, userID := range event.UserIDs {
+			userIDs = append(userIDs, userID.String())
+		}
+		h.sendToUsers(userIDs, WebSocketMessage{
+			Type:      event.Type,
+			ChatID:    chatID,
+			MessageID: event.Message.ID.String(),
+			Sender:    event.Message.SenderID.String(),
+			Text:      event.Message.Text,
+			Message:   event.Message,
+		})
+
+	case service.EventPresenceChanged:
+		// Presence is broadcast by the hub itself when it publishes the event
+
+	default:
+		log.Printf("[WARN] Ignoring unknown event type: %s", event.Type)
+	}
+}
 
+// broadcastToChat delivers a frame to the clients subscribed to a chat
+func (h *WebSocketHandler) broadcastToChat(chatID string, msg WebSocketMessage) {
+	messageBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal message: %v", err)
+		return
+	}
+
+	// Only new messages take part in resume ordering; other frames that
+	// mention a seq, such as receipts, are always delivered
+	var seq int64
+	if msg.Type == service.EventMessageCreated {
+		seq = msg.Seq
+	}
+
+	log.Printf("[DEBUG] Broadcasting message to chat %s: %s", chatID, string(messageBytes))
+	h.chatcast <- &chatBroadcast{chatID: chatID, seq: seq, message: messageBytes}
+	h.relay(chatID, seq, messageBytes)
+}
+
+// broadcastRemoval sends a frame to the subscribers of a chat, the removed
+// member included, then ends that member's subscriptions on every node
+func (h *WebSocketHandler) broadcastRemoval(chatID, userID string, msg WebSocketMessage) {
+	messageBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal message: %v", err)
+		return
+	}
+
+	h.chatcast <- &chatBroadcast{chatID: chatID, message: messageBytes, unsubscribe: userID}
+	h.publishEnvelope(cluster.Envelope{ChatID: chatID, Payload: messageBytes, Unsubscribe: userID})
+}
+
+// sendToUsers delivers a frame to every connection of the given users, on
+// this node and the others
+func (h *WebSocketHandler) sendToUsers(userIDs []string, msg WebSocketMessage) {
+	messageBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal message: %v", err)
+		return
+	}
+
+	h.deliverToUsers(userIDs, messageBytes)
+	h.relayToUsers(userIDs, messageBytes)
+}
+
+// deliverToUsers queues a frame for the local connections of the given users
+func (h *WebSocketHandler) deliverToUsers(userIDs []string, messageBytes []byte) {
+	recipients := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		recipients[userID] = true
+	}
+
+	h.clientsMux.RLock()
+	defer h.clientsMux.RUnlock()
+	for client := range h.clients {
+		if recipients[client.userID] && !client.queue(messageBytes, 0) {
+			log.Printf("[WARN] Send buffer full for client %s, dropping frame", client.userID)
+		}
+	}
 }
 
 func (h *WebSocketHandler) broadcastUserStatus(userID, status string) {
@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"rtcs/internal/cluster"
+)
+
+// UseBackplane connects the hub to other server nodes. Frames broadcast on
+// this node are published to the backplane and frames published by other
+// nodes are delivered to the local clients.
+func (h *WebSocketHandler) UseBackplane(backplane cluster.Backplane, nodeID string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-h.shutdown
+		cancel()
+	}()
+
+	if err := backplane.Subscribe(ctx, h.handleEnvelope); err != nil {
+		cancel()
+		return err
+	}
+
+	h.clientsMux.Lock()
+	h.backplane = backplane
+	h.nodeID = nodeID
+	h.clientsMux.Unlock()
+
+	log.Printf("[INFO] WebSocket hub joined cluster as node %s", nodeID)
+	return nil
+}
+
+// relay publishes a locally broadcast frame to the other nodes
+func (h *WebSocketHandler) relay(chatID string, seq int64, payload []byte) {
+	h.publishEnvelope(cluster.Envelope{ChatID: chatID, Seq: seq, Payload: payload})
+}
+
+// relayToUsers publishes a frame for specific users to the other nodes
+func (h *WebSocketHandler) relayToUsers(userIDs []string, payload []byte) {
+	h.publishEnvelope(cluster.Envelope{UserIDs: userIDs, Payload: payload})
+}
+
+func (h *WebSocketHandler) publishEnvelope(env cluster.Envelope) {
+	h.clientsMux.RLock()
+	backplane, nodeID := h.backplane, h.nodeID
+	h.clientsMux.RUnlock()
+
+	if backplane == nil {
+		return
+	}
+
+	env.NodeID = nodeID
+	if err := backplane.Publish(context.Background(), env); err != nil {
+		log.Printf("[ERROR] Failed to publish frame to cluster: %v", err)
+	}
+}
+
+// handleEnvelope delivers a frame published by another node to local clients
+func (h *WebSocketHandler) handleEnvelope(env cluster.Envelope) {
+	h.clientsMux.RLock()
+	nodeID := h.nodeID
+	h.clientsMux.RUnlock()
+
+	// Our own frames were already delivered locally
+	if env.NodeID == nodeID {
+		return
+	}
+
+	if len(env.UserIDs) > 0 {
+		h.deliverToUsers(env.UserIDs, env.Payload)
+		return
+	}
+
+	if env.ChatID != "" {
+		h.chatcast <- &chatBroadcast{chatID: env.ChatID, seq: env.Seq, message: env.Payload, unsubscribe: env.Unsubscribe}
+		return
+	}
+
+	h.broadcast <- env.Payload
+
+	// Presence changes on other nodes affect the user list shown here
+	var msg WebSocketMessage
+	if err := json.Unmarshal(env.Payload, &msg); err != nil {
+		log.Printf("[ERROR] Failed to decode cluster frame: %v", err)
+		return
+	}
+
+	switch msg.Type {
+	case "user_join", "user_leave", "status_change":
+		if msg.UserID != "" {
+			h.clientsMux.Lock()
+			h.knownUsers[msg.UserID] = true
+			h.clientsMux.Unlock()
+		}
+		h.broadcastUserList()
+	}
+}
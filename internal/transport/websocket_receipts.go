@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"fmt"
+
+	"rtcs/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// acknowledge records a delivered or read receipt sent by the client. The
+// resulting receipt frame reaches the chat through the chat service's event.
+func (c *Client) acknowledge(kind, chatIDStr, messageIDStr string) error {
+	chatID, err := uuid.Parse(chatIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID")
+	}
+	messageID, err := uuid.Parse(messageIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid message ID")
+	}
+	userID, err := uuid.Parse(c.userID)
+	if err != nil {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	if kind == "read" {
+		return c.handler.chatService.MarkRead(c.ctx, chatID, userID, messageID)
+	}
+	return c.handler.chatService.MarkDelivered(c.ctx, chatID, userID, messageID)
+}
+
+// receiptFrame builds the frame announcing a member's new delivery or read
+// position
+func receiptFrame(event service.Event) WebSocketMessage {
+	return WebSocketMessage{
+		Type:      event.Type,
+		ChatID:    event.ChatID.String(),
+		UserID:    event.UserID.String(),
+		MessageID: event.Message.ID.String(),
+		Seq:       event.Message.Seq,
+	}
+}
@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+
+	"rtcs/internal/model"
+)
+
+// replayBatchSize is how many stored messages are loaded per replay query
+const replayBatchSize = 100
+
+// messageCreatedFrame builds the frame that delivers a stored message
+func messageCreatedFrame(message *model.Message) WebSocketMessage {
+	return WebSocketMessage{
+		Type:    "message_created",
+		ChatID:  message.ChatID.String(),
+		Sender:  message.SenderID.String(),
+		Text:    message.Text,
+		Seq:     message.Seq,
+		Message: message,
+	}
+}
+
+// resume sends the messages of a chat after lastSeq and then switches the
+// chat back to live delivery. Live frames arriving during the replay are
+// held back and flushed afterwards, skipping those already replayed.
+func (c *Client) resume(chatID string, lastSeq int64) error {
+	h := c.handler
+
+	h.clientsMux.Lock()
+	if !c.chats[chatID] {
+		h.clientsMux.Unlock()
+		return errors.New("not subscribed to chat")
+	}
+	if _, ok := c.resuming[chatID]; ok {
+		h.clientsMux.Unlock()
+		return errors.New("chat is already being resumed")
+	}
+	c.resuming[chatID] = []*chatBroadcast{}
+	h.clientsMux.Unlock()
+
+	replayed, err := c.replay(chatID, lastSeq)
+
+	h.clientsMux.Lock()
+	pending := c.resuming[chatID]
+	delete(c.resuming, chatID)
+	h.clientsMux.Unlock()
+
+	for _, msg := range pending {
+		if msg.seq == 0 || msg.seq > replayed {
+			c.queue(msg.message, writeWait)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.sendMessage(WebSocketMessage{Type: "resumed", ChatID: chatID, Seq: replayed})
+	return nil
+}
+
+// replay sends stored messages after lastSeq and returns the last sequence sent
+func (c *Client) replay(chatID string, lastSeq int64) (int64, error) {
+	for {
+		messages, err := c.handler.messageService.GetMessagesSince(c.ctx, chatID, lastSeq, replayBatchSize)
+		if err != nil {
+			return lastSeq, err
+		}
+
+		for _, message := range messages {
+			msgBytes, err := json.Marshal(messageCreatedFrame(message))
+			if err != nil {
+				return lastSeq, err
+			}
+			if !c.queue(msgBytes, writeWait) {
+				return lastSeq, errors.New("client is not reading")
+			}
+			lastSeq = message.Seq
+		}
+
+		if len(messages) < replayBatchSize {
+			return lastSeq, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// typingTimeout is how long a typing indicator lasts without a refresh
+	// before the server clears it on the client's behalf
+	typingTimeout = 5 * time.Second
+	// typingThrottle is the minimum interval between typing_start frames a
+	// client may fan out for one chat; refreshes in between only extend the
+	// timeout
+	typingThrottle = 2 * time.Second
+)
+
+// typingState tracks an active typing indicator for one chat
+type typingState struct {
+	timer     *time.Timer
+	announced time.Time
+}
+
+// startTyping marks the client as typing in a chat. Indicators are never
+// persisted and expire after typingTimeout unless refreshed.
+func (c *Client) startTyping(chatID string) {
+	c.typingMux.Lock()
+	state, ok := c.typing[chatID]
+	if ok {
+		state.timer.Reset(typingTimeout)
+		if time.Since(state.announced) < typingThrottle {
+			c.typingMux.Unlock()
+			return
+		}
+		state.announced = time.Now()
+	} else {
+		state = &typingState{announced: time.Now()}
+		state.timer = time.AfterFunc(typingTimeout, func() { c.expireTyping(chatID, state) })
+		c.typing[chatID] = state
+	}
+	c.typingMux.Unlock()
+
+	c.handler.broadcastToChat(chatID, WebSocketMessage{Type: "typing_start", ChatID: chatID, UserID: c.userID})
+}
+
+// stopTyping clears the client's typing indicator in a chat, if any
+func (c *Client) stopTyping(chatID string) {
+	c.typingMux.Lock()
+	state, ok := c.typing[chatID]
+	if ok {
+		state.timer.Stop()
+		delete(c.typing, chatID)
+	}
+	c.typingMux.Unlock()
+
+	if ok {
+		c.handler.broadcastToChat(chatID, WebSocketMessage{Type: "typing_stop", ChatID: chatID, UserID: c.userID})
+	}
+}
+
+// expireTyping clears an indicator whose stop frame never arrived
+func (c *Client) expireTyping(chatID string, state *typingState) {
+	c.typingMux.Lock()
+	current := c.typing[chatID] == state
+	c.typingMux.Unlock()
+
+	if current {
+		log.Printf("[DEBUG] Typing indicator of %s in chat %s expired", c.userID, chatID)
+		c.stopTyping(chatID)
+	}
+}
+
+// stopAllTyping clears every indicator of a client that is going away
+func (c *Client) stopAllTyping() {
+	c.typingMux.Lock()
+	chatIDs := make([]string, 0, len(c.typing))
+	for chatID := range c.typing {
+		chatIDs = append(chatIDs, chatID)
+	}
+	c.typingMux.Unlock()
+
+	for _, chatID := range chatIDs {
+		c.stopTyping(chatID)
+	}
+}
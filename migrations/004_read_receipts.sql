@@ -0,0 +1,5 @@
+-- Per-member delivery and read positions
+ALTER TABLE chat_users
+ADD COLUMN IF NOT EXISTS last_delivered_seq BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_read_seq BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_read_message_id UUID;
@@ -0,0 +1,4 @@
+-- Deleted messages stay as tombstones until purged
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id),
+ADD COLUMN IF NOT EXISTS delete_reason TEXT;
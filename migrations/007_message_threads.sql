@@ -0,0 +1,7 @@
+-- Threaded replies; thread roots keep a count of their replies
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES messages(id),
+ADD COLUMN IF NOT EXISTS reply_count BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_reply_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id);
@@ -0,0 +1,4 @@
+-- Keyset pagination of chat history seeks on (created_at, id) within a chat.
+-- Only top-level messages are listed; thread replies are paged by seq.
+CREATE INDEX IF NOT EXISTS idx_messages_chat_history ON messages(chat_id, created_at, id)
+WHERE parent_id IS NULL;
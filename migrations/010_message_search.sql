@@ -0,0 +1,7 @@
+-- Full-text search over message text. The vector is generated from the text,
+-- so edits and tombstones keep it current without application changes.
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
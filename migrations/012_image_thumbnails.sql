@@ -0,0 +1,5 @@
+-- Downscaled previews of image attachments and the original dimensions
+ALTER TABLE attachments
+ADD COLUMN IF NOT EXISTS thumbnail_key VARCHAR(512),
+ADD COLUMN IF NOT EXISTS width INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS height INTEGER NOT NULL DEFAULT 0;
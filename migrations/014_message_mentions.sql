@@ -0,0 +1,13 @@
+-- Members mentioned by name in a message
+CREATE TABLE IF NOT EXISTS message_mentions (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    username VARCHAR(255) NOT NULL,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_mentions_user_id ON message_mentions(user_id);
+
+-- Set when a message mentions the whole chat with @channel
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS mentions_channel BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,30 @@
+-- Who created each chat
+ALTER TABLE chats
+ADD COLUMN IF NOT EXISTS created_by UUID REFERENCES users(id);
+
+-- Each member's role in the chat: owner, admin, member or read_only
+ALTER TABLE chat_users
+ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'member';
+
+-- Existing chats are owned by their earliest member
+UPDATE chat_users SET role = 'owner'
+FROM (
+    SELECT DISTINCT ON (chat_id) chat_id, user_id
+    FROM chat_users
+    ORDER BY chat_id, joined_at, user_id
+) AS first_member
+WHERE chat_users.chat_id = first_member.chat_id
+  AND chat_users.user_id = first_member.user_id
+  AND NOT EXISTS (
+    SELECT 1 FROM chat_users AS owner
+    WHERE owner.chat_id = chat_users.chat_id AND owner.role = 'owner'
+  );
+
+UPDATE chats SET created_by = chat_users.user_id
+FROM chat_users
+WHERE chat_users.chat_id = chats.id
+  AND chat_users.role = 'owner'
+  AND chats.created_by IS NULL;
+
+-- A chat has at most one owner
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_users_owner ON chat_users(chat_id) WHERE role = 'owner';
@@ -0,0 +1,31 @@
+-- Private chats are joined only through invites
+ALTER TABLE chats
+ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'public';
+
+-- Who added each member, directly or through an invite
+ALTER TABLE chat_users
+ADD COLUMN IF NOT EXISTS invited_by UUID REFERENCES users(id);
+
+-- Invite tokens; only a SHA-256 hash of each token is kept
+CREATE TABLE IF NOT EXISTS chat_invites (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    max_uses INTEGER NOT NULL,
+    uses INTEGER NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_by UUID REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_invites_chat_id ON chat_invites(chat_id);
+
+-- Users who joined through each invite
+CREATE TABLE IF NOT EXISTS chat_invite_uses (
+    invite_id UUID NOT NULL REFERENCES chat_invites(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (invite_id, user_id)
+);
@@ -0,0 +1,9 @@
+-- Channels, direct chats between two users and group direct chats
+ALTER TABLE chats
+ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'channel';
+
+-- Canonical key of the pair of users in a direct chat, so each pair has one
+ALTER TABLE chats
+ADD COLUMN IF NOT EXISTS dm_key VARCHAR(80);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chats_dm_key ON chats(dm_key);
@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"rtcs/internal/cache"
+	"rtcs/internal/repository"
+	"rtcs/internal/service"
+)
+
+func TestAuthService_WebSocketTicket(t *testing.T) {
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{})
+	require.NoError(t, err)
+
+	authService := service.NewAuthService(repository.NewUserRepository(gormDB), "test-jwt-secret")
+	authService.SetTicketStore(cache.NewMessageCache(redisClient))
+	ctx := context.Background()
+
+	t.Run("Ticket Is Single-Use", func(t *testing.T) {
+		ticket, err := authService.GenerateWebSocketTicket(testUserID.String())
+		require.NoError(t, err)
+
+		userID, err := authService.ValidateWebSocketTicket(ctx, ticket)
+		require.NoError(t, err)
+		assert.Equal(t, testUserID, userID)
+
+		_, err = authService.ValidateWebSocketTicket(ctx, ticket)
+		assert.ErrorIs(t, err, service.ErrTicketUsed)
+	})
+
+	t.Run("Ticket Is Not A Bearer Token", func(t *testing.T) {
+		ticket, err := authService.GenerateWebSocketTicket(testUserID.String())
+		require.NoError(t, err)
+
+		_, err = authService.ValidateToken(ctx, ticket)
+		assert.Error(t, err)
+	})
+
+	t.Run("Token Is Not A Ticket", func(t *testing.T) {
+		token, err := authService.GenerateToken(testUserID.String())
+		require.NoError(t, err)
+
+		_, err = authService.ValidateWebSocketTicket(ctx, token)
+		assert.ErrorIs(t, err, service.ErrInvalidTicket)
+
+		userID, err := authService.ValidateToken(ctx, token)
+		require.NoError(t, err)
+		assert.Equal(t, testUserID, userID)
+	})
+}
@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"rtcs/internal/storage"
+)
+
+// TestS3BlobStore runs the S3 blob store against MinIO
+func TestS3BlobStore(t *testing.T) {
+	minio, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "minio/minio",
+		Tag:        "latest",
+		Cmd:        []string{"server", "/data"},
+		Env: []string{
+			"MINIO_ROOT_USER=rtcs-test",
+			"MINIO_ROOT_PASSWORD=rtcs-test-secret",
+		},
+	}, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{
+			Name: "no",
+		}
+	})
+	require.NoError(t, err)
+	defer pool.Purge(minio)
+
+	endpoint := fmt.Sprintf("http://localhost:%s", minio.GetPort("9000/tcp"))
+	require.NoError(t, pool.Retry(func() error {
+		resp, err := http.Get(endpoint + "/minio/health/live")
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("minio not ready: %s", resp.Status)
+		}
+		return nil
+	}))
+
+	store := storage.NewS3Store(storage.S3Config{
+		Endpoint:  endpoint,
+		Bucket:    "attachments",
+		AccessKey: "rtcs-test",
+		SecretKey: "rtcs-test-secret",
+	})
+	ctx := context.Background()
+	require.NoError(t, store.CreateBucket(ctx))
+	// Creating an existing bucket is not an error
+	require.NoError(t, store.CreateBucket(ctx))
+
+	data := []byte("attachment content")
+	key := "attachments/chat/file"
+	require.NoError(t, store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "text/plain"))
+
+	body, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	got, err := io.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	require.NoError(t, store.Delete(ctx, key))
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, storage.ErrBlobNotFound)
+}
@@ -172,6 +172,12 @@ func applyMigrations() error {
 		CREATE TABLE IF NOT EXISTS chats (
 			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
 			name VARCHAR(255) NOT NULL,
+			last_seq BIGINT NOT NULL DEFAULT 0,
+			max_attachment_size BIGINT NOT NULL DEFAULT 0,
+			created_by UUID REFERENCES users(id),
+			visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+			kind VARCHAR(16) NOT NULL DEFAULT 'channel',
+			dm_key VARCHAR(80) UNIQUE,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			deleted_at TIMESTAMP WITH TIME ZONE
@@ -181,24 +187,111 @@ func applyMigrations() error {
 			chat_id UUID REFERENCES chats(id),
 			user_id UUID REFERENCES users(id),
 			joined_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			last_delivered_seq BIGINT NOT NULL DEFAULT 0,
+			last_read_seq BIGINT NOT NULL DEFAULT 0,
+			last_read_message_id UUID,
+			role VARCHAR(16) NOT NULL DEFAULT 'member',
+			invited_by UUID REFERENCES users(id),
 			PRIMARY KEY (chat_id, user_id)
 		);
 		
 		CREATE TABLE IF NOT EXISTS messages (
 			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
 			chat_id UUID NOT NULL REFERENCES chats(id),
+			seq BIGINT NOT NULL DEFAULT 0,
 			sender_id UUID NOT NULL REFERENCES users(id),
+			parent_id UUID REFERENCES messages(id),
 			text TEXT NOT NULL,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			deleted_at TIMESTAMP WITH TIME ZONE
+			edited_at TIMESTAMP WITH TIME ZONE,
+			deleted_at TIMESTAMP WITH TIME ZONE,
+			deleted_by UUID REFERENCES users(id),
+			delete_reason TEXT,
+			reply_count BIGINT NOT NULL DEFAULT 0,
+			last_reply_at TIMESTAMP WITH TIME ZONE,
+			mentions_channel BOOLEAN NOT NULL DEFAULT FALSE,
+			search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', text)) STORED
+		);
+		
+		CREATE TABLE IF NOT EXISTS message_revisions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			text TEXT NOT NULL,
+			edited_by UUID NOT NULL REFERENCES users(id),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		
+		CREATE TABLE IF NOT EXISTS attachments (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+			message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+			uploader_id UUID NOT NULL REFERENCES users(id),
+			file_name VARCHAR(255) NOT NULL,
+			content_type VARCHAR(255) NOT NULL,
+			size BIGINT NOT NULL,
+			storage_key VARCHAR(512) NOT NULL,
+			thumbnail_key VARCHAR(512),
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+		
+		CREATE TABLE IF NOT EXISTS message_mentions (
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			username VARCHAR(255) NOT NULL,
+			PRIMARY KEY (message_id, user_id)
+		);
+		
+		CREATE TABLE IF NOT EXISTS chat_pins (
+			chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			pinned_by UUID NOT NULL REFERENCES users(id),
+			pinned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (chat_id, message_id)
+		);
+		
+		CREATE TABLE IF NOT EXISTS chat_invites (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			created_by UUID NOT NULL REFERENCES users(id),
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			max_uses INTEGER NOT NULL,
+			uses INTEGER NOT NULL DEFAULT 0,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			revoked_by UUID REFERENCES users(id)
+		);
+		
+		CREATE TABLE IF NOT EXISTS chat_invite_uses (
+			invite_id UUID NOT NULL REFERENCES chat_invites(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (invite_id, user_id)
+		);
+		
+		CREATE TABLE IF NOT EXISTS message_reactions (
+			message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			emoji VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (message_id, user_id, emoji)
 		);
 		
 		CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
 		CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);
+		CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id);
+		CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
+		CREATE INDEX IF NOT EXISTS idx_messages_chat_history ON messages(chat_id, created_at, id) WHERE parent_id IS NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_chat_id_seq ON messages(chat_id, seq);
+		CREATE INDEX IF NOT EXISTS idx_message_mentions_user_id ON message_mentions(user_id);
 		CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
 		CREATE INDEX IF NOT EXISTS idx_chat_users_user_id ON chat_users(user_id);
 		CREATE INDEX IF NOT EXISTS idx_chat_users_chat_id ON chat_users(chat_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_users_owner ON chat_users(chat_id) WHERE role = 'owner';
+		CREATE INDEX IF NOT EXISTS idx_chat_invites_chat_id ON chat_invites(chat_id);
 		`,
 	}
 
@@ -248,7 +341,35 @@ func createTestData() error {
 
 // Helper function to cleanup test data
 func cleanupTestData() error {
-	_, err := db.Exec("DELETE FROM messages")
+	_, err := db.Exec("DELETE FROM chat_invite_uses")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM chat_invites")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM chat_pins")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM message_mentions")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM attachments")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM message_reactions")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM message_revisions")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM messages")
 	if err != nil {
 		return err
 	}
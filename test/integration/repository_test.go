@@ -157,6 +157,33 @@ func TestRepository_Chat(t *testing.T) {
 		assert.Equal(t, 1, count)
 	})
 
+	t.Run("New Member Starts At End Of History", func(t *testing.T) {
+		chat := &model.Chat{Name: "Chat With History"}
+		require.NoError(t, chatRepo.CreateChat(ctx, chat))
+		_, err = db.Exec("UPDATE chats SET last_seq = 7 WHERE id = $1", chat.ID)
+		require.NoError(t, err)
+
+		newUserID := uuid.New()
+		_, err = db.Exec(
+			"INSERT INTO users (id, username, password) VALUES ($1, $2, $3)",
+			newUserID, "latejoiner", "password",
+		)
+		require.NoError(t, err)
+
+		err = chatRepo.AddUserToChat(ctx, chat.ID, newUserID)
+		require.NoError(t, err)
+
+		member, err := chatRepo.GetChatMember(ctx, chat.ID, newUserID)
+		require.NoError(t, err)
+		require.NotNil(t, member)
+		assert.Equal(t, int64(7), member.LastReadSeq)
+		assert.Equal(t, int64(7), member.LastDeliveredSeq)
+
+		unread, err := chatRepo.CountUnreadMessages(ctx, newUserID, []uuid.UUID{chat.ID})
+		require.NoError(t, err)
+		assert.Zero(t, unread[chat.ID])
+	})
+
 	t.Run("Remove User From Chat", func(t *testing.T) {
 		var userID uuid.UUID
 		err = db.QueryRow(
@@ -283,4 +310,55 @@ func TestRepository_Message(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, deletedAt)
 	})
+
+	t.Run("Purge Deleted Messages", func(t *testing.T) {
+		save := func(text string, parentID *uuid.UUID) *model.Message {
+			message := &model.Message{
+				ID:        uuid.New(),
+				ChatID:    testChatID,
+				SenderID:  testUserID,
+				Text:      text,
+				ParentID:  parentID,
+				CreatedAt: time.Now(),
+			}
+			require.NoError(t, messageRepo.SaveMessage(ctx, message))
+			return message
+		}
+		exists := func(id uuid.UUID) bool {
+			var count int
+			require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM messages WHERE id = $1", id).Scan(&count))
+			return count == 1
+		}
+
+		root := save("Thread root", nil)
+		reply := save("Thread reply", &root.ID)
+		lone := save("Lone message", nil)
+
+		deletedAt := time.Now().Add(-48 * time.Hour)
+		cutoff := time.Now().Add(-24 * time.Hour)
+		for _, id := range []uuid.UUID{root.ID, lone.ID} {
+			_, err := messageRepo.TombstoneMessage(ctx, id, testUserID, "", deletedAt)
+			require.NoError(t, err)
+		}
+
+		// A tombstoned root with replies does not stop the purge
+		purged, err := messageRepo.PurgeDeletedMessages(ctx, cutoff)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		assert.True(t, exists(root.ID))
+		assert.False(t, exists(lone.ID))
+
+		// The root goes once its replies are gone
+		_, err = messageRepo.TombstoneMessage(ctx, reply.ID, testUserID, "", deletedAt)
+		require.NoError(t, err)
+		purged, err = messageRepo.PurgeDeletedMessages(ctx, cutoff)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		assert.False(t, exists(reply.ID))
+
+		purged, err = messageRepo.PurgeDeletedMessages(ctx, cutoff)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		assert.False(t, exists(root.ID))
+	})
 }